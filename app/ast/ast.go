@@ -3,7 +3,10 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"math/big"
+	"sort"
 	"strings"
+	"unicode"
 
 	"github.com/udeshyadhungana/interprerer/app/token"
 )
@@ -11,6 +14,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the first character of the node
+	End() token.Position // position immediately after the node
 }
 
 type Expression interface {
@@ -45,6 +50,20 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 /* ThoosMuji statement */
 type ThoosMujiStatement struct {
 	Token token.Token
@@ -54,6 +73,16 @@ type ThoosMujiStatement struct {
 
 func (tms *ThoosMujiStatement) statementNode()       {}
 func (tms *ThoosMujiStatement) TokenLiteral() string { return tms.Token.Literal }
+func (tms *ThoosMujiStatement) Pos() token.Position  { return tms.Token.Pos }
+func (tms *ThoosMujiStatement) End() token.Position {
+	if tms.Value != nil {
+		return tms.Value.End()
+	}
+	if tms.Name != nil {
+		return tms.Name.End()
+	}
+	return tms.Token.End
+}
 
 func (tms *ThoosMujiStatement) String() string {
 	var out bytes.Buffer
@@ -79,16 +108,21 @@ type Identifier struct {
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 
 /* Integer literal */
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // set instead of Value when the literal does not fit in an int64
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
 
 /* Float literal */
 type FloatLiteral struct {
@@ -99,6 +133,8 @@ type FloatLiteral struct {
 func (il *FloatLiteral) expressionNode()      {}
 func (il *FloatLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *FloatLiteral) String() string       { return il.Token.Literal }
+func (il *FloatLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *FloatLiteral) End() token.Position  { return il.Token.End }
 
 /* Pathamuji statement */
 type PathaMujiStatement struct {
@@ -108,6 +144,13 @@ type PathaMujiStatement struct {
 
 func (pms *PathaMujiStatement) statementNode()       {}
 func (pms *PathaMujiStatement) TokenLiteral() string { return pms.Token.Literal }
+func (pms *PathaMujiStatement) Pos() token.Position  { return pms.Token.Pos }
+func (pms *PathaMujiStatement) End() token.Position {
+	if pms.Value != nil {
+		return pms.Value.End()
+	}
+	return pms.Token.End
+}
 
 func (pms *PathaMujiStatement) String() string {
 	var out bytes.Buffer
@@ -129,6 +172,13 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
 func (es *ExpressionStatement) String() string {
 	var out bytes.Buffer
 	if es.Expression != nil {
@@ -146,6 +196,13 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position {
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+	return pe.Token.End
+}
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -166,6 +223,18 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *InfixExpression) End() token.Position {
+	if ie.Right != nil {
+		return ie.Right.End()
+	}
+	return ie.Token.End
+}
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -187,15 +256,28 @@ type Boolean struct {
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
 
 // block
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	Rbrace     token.Token // closing brace
 }
 
 func (y *BlockStatement) statementNode()       {}
 func (y *BlockStatement) TokenLiteral() string { return y.Token.Literal }
+func (y *BlockStatement) Pos() token.Position  { return y.Token.Pos }
+func (y *BlockStatement) End() token.Position {
+	if y.Rbrace.End.IsValid() {
+		return y.Rbrace.End
+	}
+	if len(y.Statements) > 0 && y.Statements[len(y.Statements)-1] != nil {
+		return y.Statements[len(y.Statements)-1].End()
+	}
+	return y.Token.End
+}
 func (y *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -220,6 +302,19 @@ type YediMujiExpression struct {
 
 func (y *YediMujiExpression) expressionNode()      {}
 func (y *YediMujiExpression) TokenLiteral() string { return y.Token.Literal }
+func (y *YediMujiExpression) Pos() token.Position  { return y.Token.Pos }
+func (y *YediMujiExpression) End() token.Position {
+	if y.Fallback != nil {
+		return y.Fallback.End()
+	}
+	if len(y.Alternatives) > 0 && y.Alternatives[len(y.Alternatives)-1] != nil {
+		return y.Alternatives[len(y.Alternatives)-1].End()
+	}
+	if y.Consequent != nil {
+		return y.Consequent.End()
+	}
+	return y.Token.End
+}
 func (y *YediMujiExpression) String() string {
 	var out bytes.Buffer
 
@@ -250,6 +345,13 @@ type NabhaeMujiExpression struct {
 
 func (y *NabhaeMujiExpression) expressionNode()      {}
 func (y *NabhaeMujiExpression) TokenLiteral() string { return y.Token.Literal }
+func (y *NabhaeMujiExpression) Pos() token.Position  { return y.Token.Pos }
+func (y *NabhaeMujiExpression) End() token.Position {
+	if y.Consequent != nil {
+		return y.Consequent.End()
+	}
+	return y.Token.End
+}
 func (y *NabhaeMujiExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("nabhae_muji (")
@@ -268,6 +370,13 @@ type KaamGarMujiExpression struct {
 
 func (f *KaamGarMujiExpression) expressionNode()      {}
 func (f *KaamGarMujiExpression) TokenLiteral() string { return f.Token.Literal }
+func (f *KaamGarMujiExpression) Pos() token.Position  { return f.Token.Pos }
+func (f *KaamGarMujiExpression) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+	return f.Token.End
+}
 func (f *KaamGarMujiExpression) String() string {
 	var out bytes.Buffer
 
@@ -290,13 +399,26 @@ func (f *KaamGarMujiExpression) String() string {
 
 // call expression
 type CallExpression struct {
-	Token     token.Token
+	Token     token.Token // the ( token
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Token
 }
 
 func (f *CallExpression) expressionNode()      {}
 func (f *CallExpression) TokenLiteral() string { return f.Token.Literal }
+func (f *CallExpression) Pos() token.Position {
+	if f.Function != nil {
+		return f.Function.Pos()
+	}
+	return f.Token.Pos
+}
+func (f *CallExpression) End() token.Position {
+	if f.Rparen.End.IsValid() {
+		return f.Rparen.End
+	}
+	return f.Token.End
+}
 func (f *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -319,17 +441,101 @@ type StringExpression struct {
 
 func (s *StringExpression) expressionNode()      {}
 func (s *StringExpression) TokenLiteral() string { return s.Token.Literal }
+func (s *StringExpression) Pos() token.Position  { return s.Token.Pos }
+func (s *StringExpression) End() token.Position  { return s.Token.End }
 func (s *StringExpression) String() string {
-	return fmt.Sprintf("\"%s\"", s.Value)
+	return Quote(s.Value)
+}
+
+// Quote writes s back as a muji string literal, escaping what the lexer decodes
+func Quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	writeEscaped(&out, s)
+	out.WriteByte('"')
+	return out.String()
+}
+
+func writeEscaped(out *strings.Builder, s string) {
+	for i, ch := range s {
+		switch ch {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteRune(ch)
+		case '$':
+			// would start an interpolation
+			if strings.HasPrefix(s[i:], "${") {
+				out.WriteByte('\\')
+			}
+			out.WriteRune(ch)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		case 0:
+			out.WriteString(`\0`)
+		default:
+			if unicode.IsPrint(ch) {
+				out.WriteRune(ch)
+			} else if ch <= 0xFFFF {
+				fmt.Fprintf(out, `\u%04X`, ch)
+			} else {
+				fmt.Fprintf(out, `\U%08X`, ch)
+			}
+		}
+	}
+}
+
+// a string with interpolations, "a ${x} b"
+// Texts has one more element than Values, Values[i] goes between Texts[i] and Texts[i+1]
+type InterpolatedStringExpression struct {
+	Token  token.Token // STRING_HEAD
+	Texts  []string
+	Values []Expression
+	Tail   token.Token // STRING_TAIL
+}
+
+func (s *InterpolatedStringExpression) expressionNode()      {}
+func (s *InterpolatedStringExpression) TokenLiteral() string { return s.Token.Literal }
+func (s *InterpolatedStringExpression) Pos() token.Position  { return s.Token.Pos }
+func (s *InterpolatedStringExpression) End() token.Position {
+	if s.Tail.End.IsValid() {
+		return s.Tail.End
+	}
+	return s.Token.End
+}
+func (s *InterpolatedStringExpression) String() string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i, text := range s.Texts {
+		writeEscaped(&out, text)
+		if i < len(s.Values) {
+			out.WriteString("${")
+			out.WriteString(s.Values[i].String())
+			out.WriteString("}")
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
 }
 
 type ArrayExpression struct {
 	Token    token.Token
 	Elements []Expression
+	Rbracket token.Token
 }
 
 func (a *ArrayExpression) expressionNode()      {}
 func (a *ArrayExpression) TokenLiteral() string { return a.Token.Literal }
+func (a *ArrayExpression) Pos() token.Position  { return a.Token.Pos }
+func (a *ArrayExpression) End() token.Position {
+	if a.Rbracket.End.IsValid() {
+		return a.Rbracket.End
+	}
+	return a.Token.End
+}
 func (a *ArrayExpression) String() string {
 	var out bytes.Buffer
 
@@ -343,6 +549,33 @@ func (a *ArrayExpression) String() string {
 	return out.String()
 }
 
+// (a, b), (a,) or ()
+type TupleExpression struct {
+	Token    token.Token // the ( token
+	Elements []Expression
+	Rparen   token.Token
+}
+
+func (t *TupleExpression) expressionNode()      {}
+func (t *TupleExpression) TokenLiteral() string { return t.Token.Literal }
+func (t *TupleExpression) Pos() token.Position  { return t.Token.Pos }
+func (t *TupleExpression) End() token.Position {
+	if t.Rparen.End.IsValid() {
+		return t.Rparen.End
+	}
+	return t.Token.End
+}
+func (t *TupleExpression) String() string {
+	var eachString []string
+	for _, e := range t.Elements {
+		eachString = append(eachString, e.String())
+	}
+	if len(eachString) == 1 {
+		return "(" + eachString[0] + ",)"
+	}
+	return "(" + strings.Join(eachString, ", ") + ")"
+}
+
 type JabasammaMujiExpression struct {
 	Token      token.Token
 	Condition  Expression
@@ -353,6 +586,13 @@ func (c *JabasammaMujiExpression) expressionNode() {}
 func (c *JabasammaMujiExpression) TokenLiteral() string {
 	return c.Token.Literal
 }
+func (c *JabasammaMujiExpression) Pos() token.Position { return c.Token.Pos }
+func (c *JabasammaMujiExpression) End() token.Position {
+	if c.Consequent != nil {
+		return c.Consequent.End()
+	}
+	return c.Token.End
+}
 func (c *JabasammaMujiExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("jaba_samma_muji (")
@@ -374,6 +614,13 @@ func (g *GhumaMujiExpression) expressionNode() {}
 func (g *GhumaMujiExpression) TokenLiteral() string {
 	return g.Token.Literal
 }
+func (g *GhumaMujiExpression) Pos() token.Position { return g.Token.Pos }
+func (g *GhumaMujiExpression) End() token.Position {
+	if g.Body != nil {
+		return g.Body.End()
+	}
+	return g.Token.End
+}
 func (g *GhumaMujiExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("ghuma_muji (")
@@ -385,43 +632,268 @@ func (g *GhumaMujiExpression) String() string {
 	return out.String()
 }
 
+// ghuma_muji (thoos_muji v : iterable) or ghuma_muji (thoos_muji k, v : iterable)
+// Key is nil in the single variable form
+type GhumaMujiEachExpression struct {
+	Token    token.Token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (g *GhumaMujiEachExpression) expressionNode() {}
+func (g *GhumaMujiEachExpression) TokenLiteral() string {
+	return g.Token.Literal
+}
+func (g *GhumaMujiEachExpression) Pos() token.Position { return g.Token.Pos }
+func (g *GhumaMujiEachExpression) End() token.Position {
+	if g.Body != nil {
+		return g.Body.End()
+	}
+	return g.Token.End
+}
+func (g *GhumaMujiEachExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("ghuma_muji (thoos_muji ")
+	if g.Key != nil {
+		out.WriteString(g.Key.String())
+		out.WriteString(", ")
+	}
+	out.WriteString(g.Value.String())
+	out.WriteString(" : ")
+	out.WriteString(g.Iterable.String())
+	out.WriteString(")")
+	out.WriteString(g.Body.String())
+	return out.String()
+}
+
 /* Hash */
 type HashExpression struct {
-	Token token.Token
-	Pairs map[Expression]Expression
+	Token  token.Token
+	Pairs  map[Expression]Expression
+	Rbrace token.Token
 }
 
 func (h *HashExpression) expressionNode() {}
 func (h *HashExpression) TokenLiteral() string {
 	return h.Token.Literal
 }
+func (h *HashExpression) Pos() token.Position { return h.Token.Pos }
+func (h *HashExpression) End() token.Position {
+	if h.Rbrace.End.IsValid() {
+		return h.Rbrace.End
+	}
+	return h.Token.End
+}
+
+// keys in the order they appear in the source
+func (h *HashExpression) Keys() []Expression {
+	keys := make([]Expression, 0, len(h.Pairs))
+	for key := range h.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Pos().Offset < keys[j].Pos().Offset
+	})
+	return keys
+}
 
 func (h *HashExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("{")
 	var inside []string
-	for key, val := range h.Pairs {
-		inside = append(inside, fmt.Sprintf("%s%s%s", key.String(), ": ", val.String()))
+	for _, key := range h.Keys() {
+		inside = append(inside, fmt.Sprintf("%s%s%s", key.String(), ": ", h.Pairs[key].String()))
 	}
 	out.WriteString(strings.Join(inside, ", "))
 	out.WriteString("}")
 	return out.String()
 }
 
+// {a, b}, an empty set has no literal
+type SetExpression struct {
+	Token    token.Token // the { token
+	Elements []Expression
+	Rbrace   token.Token
+}
+
+func (s *SetExpression) expressionNode()      {}
+func (s *SetExpression) TokenLiteral() string { return s.Token.Literal }
+func (s *SetExpression) Pos() token.Position  { return s.Token.Pos }
+func (s *SetExpression) End() token.Position {
+	if s.Rbrace.End.IsValid() {
+		return s.Rbrace.End
+	}
+	return s.Token.End
+}
+func (s *SetExpression) String() string {
+	var eachString []string
+	for _, e := range s.Elements {
+		eachString = append(eachString, e.String())
+	}
+	return "{" + strings.Join(eachString, ", ") + "}"
+}
+
 // when you index an array or hashmap
 type IndexExpression struct {
-	Token   token.Token
-	Operand Expression
-	Index   Expression
+	Token    token.Token // the [ token
+	Operand  Expression
+	Index    Expression
+	Rbracket token.Token
 }
 
 func (a *IndexExpression) expressionNode()      {}
 func (a *IndexExpression) TokenLiteral() string { return a.Token.Literal }
+func (a *IndexExpression) Pos() token.Position {
+	if a.Operand != nil {
+		return a.Operand.Pos()
+	}
+	return a.Token.Pos
+}
+func (a *IndexExpression) End() token.Position {
+	if a.Rbracket.End.IsValid() {
+		return a.Rbracket.End
+	}
+	return a.Token.End
+}
 func (a *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString(a.Operand.String())
 	out.WriteString("[")
-	out.WriteString(fmt.Sprintf("%d", a.Index))
+	out.WriteString(a.Index.String())
+	out.WriteString("]")
+	return out.String()
+}
+
+// operand[low:high], either bound can be left out
+type SliceExpression struct {
+	Token    token.Token // the [ token
+	Operand  Expression
+	Low      Expression // nil when left out
+	High     Expression // nil when left out
+	Rbracket token.Token
+}
+
+func (s *SliceExpression) expressionNode()      {}
+func (s *SliceExpression) TokenLiteral() string { return s.Token.Literal }
+func (s *SliceExpression) Pos() token.Position {
+	if s.Operand != nil {
+		return s.Operand.Pos()
+	}
+	return s.Token.Pos
+}
+func (s *SliceExpression) End() token.Position {
+	if s.Rbracket.End.IsValid() {
+		return s.Rbracket.End
+	}
+	return s.Token.End
+}
+func (s *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(s.Operand.String())
+	out.WriteString("[")
+	if s.Low != nil {
+		out.WriteString(s.Low.String())
+	}
+	out.WriteString(":")
+	if s.High != nil {
+		out.WriteString(s.High.String())
+	}
 	out.WriteString("]")
 	return out.String()
 }
+
+/* Fyakmuji statement */
+type FyakMujiStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (fms *FyakMujiStatement) statementNode()       {}
+func (fms *FyakMujiStatement) TokenLiteral() string { return fms.Token.Literal }
+func (fms *FyakMujiStatement) Pos() token.Position  { return fms.Token.Pos }
+func (fms *FyakMujiStatement) End() token.Position {
+	if fms.Value != nil {
+		return fms.Value.End()
+	}
+	return fms.Token.End
+}
+
+func (fms *FyakMujiStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fms.TokenLiteral() + " ")
+	if fms.Value != nil {
+		out.WriteString(fms.Value.String())
+	}
+
+	out.WriteString(";")
+	return out.String()
+}
+
+// kosis_gar_muji { ... } samat_muji (e) { ... } jasari_pani_muji { ... }
+// at least one of Catch and Finally is present
+type KosisGarMujiExpression struct {
+	Token   token.Token
+	Body    *BlockStatement
+	Param   *Identifier // the caught error is bound to it, may be nil
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (k *KosisGarMujiExpression) expressionNode()      {}
+func (k *KosisGarMujiExpression) TokenLiteral() string { return k.Token.Literal }
+func (k *KosisGarMujiExpression) Pos() token.Position  { return k.Token.Pos }
+func (k *KosisGarMujiExpression) End() token.Position {
+	if k.Finally != nil {
+		return k.Finally.End()
+	}
+	if k.Catch != nil {
+		return k.Catch.End()
+	}
+	if k.Body != nil {
+		return k.Body.End()
+	}
+	return k.Token.End
+}
+func (k *KosisGarMujiExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("kosis_gar_muji ")
+	out.WriteString(k.Body.String())
+	if k.Catch != nil {
+		out.WriteString(" samat_muji ")
+		if k.Param != nil {
+			out.WriteString("(" + k.Param.String() + ") ")
+		}
+		out.WriteString(k.Catch.String())
+	}
+	if k.Finally != nil {
+		out.WriteString(" jasari_pani_muji ")
+		out.WriteString(k.Finally.String())
+	}
+	return out.String()
+}
+
+// ruk_muji leaves the innermost loop
+type RukMujiStatement struct {
+	Token token.Token
+}
+
+func (r *RukMujiStatement) statementNode()       {}
+func (r *RukMujiStatement) TokenLiteral() string { return r.Token.Literal }
+func (r *RukMujiStatement) Pos() token.Position  { return r.Token.Pos }
+func (r *RukMujiStatement) End() token.Position  { return r.Token.End }
+func (r *RukMujiStatement) String() string       { return r.TokenLiteral() + ";" }
+
+// arko_muji skips to the next iteration of the innermost loop
+type ArkoMujiStatement struct {
+	Token token.Token
+}
+
+func (a *ArkoMujiStatement) statementNode()       {}
+func (a *ArkoMujiStatement) TokenLiteral() string { return a.Token.Literal }
+func (a *ArkoMujiStatement) Pos() token.Position  { return a.Token.Pos }
+func (a *ArkoMujiStatement) End() token.Position  { return a.Token.End }
+func (a *ArkoMujiStatement) String() string       { return a.TokenLiteral() + ";" }
@@ -27,3 +27,22 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"foobar", `"foobar"`},
+		{"say \"hi\"\n", `"say \"hi\"\n"`},
+		{"a\tb\\c\r\x00", `"a\tb\\c\r\0"`},
+		{"नमस्ते 😀", `"नमस्ते 😀"`},
+		{"\u200b\U000e0001", `"\u200B\U000E0001"`},
+	}
+
+	for _, tt := range tests {
+		if got := Quote(tt.input); got != tt.expected {
+			t.Errorf("Quote(%q) wrong. expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
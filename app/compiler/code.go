@@ -0,0 +1,231 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpNull
+	OpTrue
+	OpFalse
+	OpPop
+
+	// infix and prefix operators
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpEqual
+	OpNotEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpUnion
+	OpIntersection
+	OpSymmetricDifference
+	OpMinus
+	OpBang
+
+	OpJump
+	OpJumpNotTruthy
+	// && and ||, they jump over the right operand and keep the left one when it decides the result
+	OpAnd
+	OpOr
+
+	// the define and set operations leave the value on the stack
+	OpGetGlobal
+	OpDefineGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpDefineLocal
+	OpSetLocal
+	OpGetFree
+	OpSetFree
+	OpGetBuiltin
+	OpEnterScope
+
+	OpArray
+	OpTuple
+	OpSet
+	OpHash
+	OpInterpolate
+	OpIndex
+	OpSetIndex
+	OpSlice
+
+	OpClosure
+	OpCall
+	OpReturnValue
+
+	OpTry
+	OpEndTry
+	OpThrow
+
+	OpIter
+	OpIterNext
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:     {"OpConstant", []int{2}},
+	OpNull:         {"OpNull", []int{}},
+	OpTrue:         {"OpTrue", []int{}},
+	OpFalse:        {"OpFalse", []int{}},
+	OpPop:          {"OpPop", []int{}},
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreater:      {"OpGreater", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLess:         {"OpLess", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	// set algebra, difference is OpSub
+	OpUnion:               {"OpUnion", []int{}},
+	OpIntersection:        {"OpIntersection", []int{}},
+	OpSymmetricDifference: {"OpSymmetricDifference", []int{}},
+	OpMinus:               {"OpMinus", []int{}},
+	OpBang:                {"OpBang", []int{}},
+	OpJump:                {"OpJump", []int{2}},
+	OpJumpNotTruthy:       {"OpJumpNotTruthy", []int{2}},
+	OpAnd:                 {"OpAnd", []int{2}},
+	OpOr:                  {"OpOr", []int{2}},
+	OpGetGlobal:           {"OpGetGlobal", []int{2}},
+	OpDefineGlobal:        {"OpDefineGlobal", []int{2}},
+	OpSetGlobal:           {"OpSetGlobal", []int{2}},
+	OpGetLocal:            {"OpGetLocal", []int{2}},
+	OpDefineLocal:         {"OpDefineLocal", []int{2}},
+	OpSetLocal:            {"OpSetLocal", []int{2}},
+	OpGetFree:             {"OpGetFree", []int{1}},
+	OpSetFree:             {"OpSetFree", []int{1}},
+	// the operand is the constant holding the builtin's name
+	OpGetBuiltin: {"OpGetBuiltin", []int{2}},
+	// first slot and number of slots of the scope
+	OpEnterScope: {"OpEnterScope", []int{2, 2}},
+	OpArray:      {"OpArray", []int{2}},
+	OpTuple:      {"OpTuple", []int{2}},
+	OpSet:        {"OpSet", []int{2}},
+	OpHash:       {"OpHash", []int{2}},
+	// the operand is the number of parts to join
+	OpInterpolate: {"OpInterpolate", []int{2}},
+	OpIndex:       {"OpIndex", []int{}},
+	OpSetIndex:    {"OpSetIndex", []int{}},
+	// operand, low and high are on the stack, a missing bound is null
+	OpSlice:       {"OpSlice", []int{}},
+	OpClosure:     {"OpClosure", []int{2}},
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	// the operand is where the handler starts
+	OpTry:    {"OpTry", []int{2}},
+	OpEndTry: {"OpEndTry", []int{}},
+	OpThrow:  {"OpThrow", []int{}},
+	// the operand is 1 when the loop has a key variable
+	OpIter: {"OpIter", []int{1}},
+	// key flag and where to jump once the iterator runs out
+	OpIterNext: {"OpIterNext", []int{1, 2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// encodes an instruction, operands are big endian
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+	return instruction
+}
+
+// decodes the operands following an opcode, also returns how many bytes they took
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ins[offset])
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// disassembles the instructions, one per line
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			return out.String()
+		}
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d", len(operands), len(def.OperandWidths))
+	}
+	switch len(operands) {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+	return fmt.Sprintf("ERROR: unhandled operand count for %s", def.Name)
+}
@@ -0,0 +1,934 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/udeshyadhungana/interprerer/app/ast"
+	"github.com/udeshyadhungana/interprerer/app/eval"
+	"github.com/udeshyadhungana/interprerer/app/object"
+	"github.com/udeshyadhungana/interprerer/app/token"
+)
+
+// Function is a compiled kaam_gar_muji, it lives in the constant pool
+// the top level of a program is compiled into one as well
+type Function struct {
+	Instructions Instructions
+	Parameters   []string
+	NumLocals    int // parameters included
+	Upvalues     []Upvalue
+	Positions    []Position
+	// the variable or callee an instruction refers to, for error messages and tracebacks
+	Names map[int]string
+}
+
+// Upvalue tells a closure where to find a captured variable when it is created:
+// a local slot of the enclosing function or one of the enclosing closure's own upvalues
+type Upvalue struct {
+	Local bool
+	Index int
+}
+
+// the instructions from Offset up to the next entry were compiled from the node at Pos
+type Position struct {
+	Offset int
+	Pos    token.Position
+}
+
+func (f *Function) Type() object.ObjectType { return object.KAAM_GAR_MUJI_OBJ }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+	out.WriteString("fn")
+	out.WriteString("(")
+	out.WriteString(strings.Join(f.Parameters, ", "))
+	out.WriteString(") {\n")
+	out.WriteString("...")
+	out.WriteString("\n}")
+	return out.String()
+}
+
+// the source position of the instruction at offset
+func (f *Function) PosAt(offset int) token.Position {
+	i := sort.Search(len(f.Positions), func(i int) bool {
+		return f.Positions[i].Offset > offset
+	})
+	if i == 0 {
+		return token.Position{}
+	}
+	return f.Positions[i-1].Pos
+}
+
+type Bytecode struct {
+	Main      *Function
+	Constants []object.Object
+	Globals   []string // names of the globals, indexed by slot
+}
+
+type Compiler struct {
+	constants []object.Object
+	globals   *SymbolTable
+	fs        *funcState
+	main      *Function
+	pos       token.Position // position of the node being compiled
+
+	// literals that are already in the constant pool
+	ints    map[int64]int
+	floats  map[float64]int
+	strings map[string]int
+}
+
+// funcState is what the compiler tracks for the function it is compiling
+type funcState struct {
+	outer    *funcState
+	fn       *Function
+	scope    *scope
+	upvalues map[Upvalue]int
+	loops    []*loop
+	tries    []*try
+}
+
+type loop struct {
+	tries     int // kosis_gar_muji blocks that were open when the loop started
+	breaks    []int
+	continues []int
+}
+
+type try struct {
+	finally *ast.BlockStatement
+	handler bool // an OpTry handler is installed around the code being compiled
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), nil)
+}
+
+// the repl passes the same symbol table for every line, along with the constants of the line before,
+// functions defined on earlier lines still refer to their constants by index
+func NewWithState(globals *SymbolTable, constants []object.Object) *Compiler {
+	c := &Compiler{
+		globals: globals,
+		ints:    make(map[int64]int),
+		floats:  make(map[float64]int),
+		strings: make(map[string]int),
+	}
+	for _, obj := range constants {
+		c.addConstant(obj)
+	}
+	return c
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Main:      c.main,
+		Constants: c.constants,
+		Globals:   c.globals.Names(),
+	}
+}
+
+// compiles the program into a function that returns the value of its last statement, like eval.Eval does
+func (c *Compiler) Compile(program *ast.Program) error {
+	c.fs = newFuncState(nil, &Function{})
+	c.fs.scope = &scope{global: true}
+	c.hoist(program.Statements)
+	if err := c.compileStatements(program.Statements); err != nil {
+		return err
+	}
+	c.emit(OpReturnValue)
+	if err := checkSize(c.fs.fn, token.Position{}); err != nil {
+		return err
+	}
+	if len(c.constants) > math.MaxUint16+1 {
+		return fmt.Errorf("program has too many constants")
+	}
+	c.main = c.fs.fn
+	c.fs = nil
+	return nil
+}
+
+// jumps address instructions with two bytes
+func checkSize(fn *Function, pos token.Position) error {
+	if len(fn.Instructions) <= math.MaxUint16 {
+		return nil
+	}
+	if pos.IsValid() {
+		return fmt.Errorf("%s: function is too large", pos)
+	}
+	return fmt.Errorf("program is too large, split it into functions")
+}
+
+func newFuncState(outer *funcState, fn *Function) *funcState {
+	fn.Names = make(map[int]string)
+	return &funcState{
+		outer:    outer,
+		fn:       fn,
+		scope:    &scope{names: make(map[string]int)},
+		upvalues: make(map[Upvalue]int),
+	}
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	// instructions emitted for a node point back at it, unless a child node emitted them
+	prev := c.pos
+	c.pos = node.Pos()
+	defer func() { c.pos = prev }()
+
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return c.compile(node.Expression)
+	case *ast.BlockStatement:
+		return c.compileStatements(node.Statements)
+	case *ast.ThoosMujiStatement:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		// declared after the value, so `thoos_muji x = x + 1` reads the outer x
+		c.emitDefine(c.declare(node.Name.Value), node.Name.Value)
+	case *ast.PathaMujiStatement:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		if err := c.unwindTries(0); err != nil {
+			return err
+		}
+		c.emit(OpReturnValue)
+	case *ast.RukMujiStatement:
+		return c.compileLoopJump(true)
+	case *ast.ArkoMujiStatement:
+		return c.compileLoopJump(false)
+	case *ast.FyakMujiStatement:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(OpThrow)
+	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			c.emitConstant(&object.BigInteger{Value: node.Big})
+		} else {
+			c.emitConstant(&object.Integer{Value: node.Value})
+		}
+	case *ast.FloatLiteral:
+		c.emitConstant(&object.Float{Value: node.Value})
+	case *ast.StringExpression:
+		c.emitConstant(&object.String{Value: node.Value})
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(OpTrue)
+		} else {
+			c.emit(OpFalse)
+		}
+	case *ast.Identifier:
+		c.emitGet(c.resolve(node.Value, true), node.Value)
+	case *ast.PrefixExpression:
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(OpBang)
+		case "-":
+			c.emit(OpMinus)
+		default:
+			return fmt.Errorf("%s: unknown operator: %s", node.Pos(), node.Operator)
+		}
+	case *ast.InfixExpression:
+		return c.compileInfix(node)
+	case *ast.YediMujiExpression:
+		return c.compileYediMuji(node)
+	case *ast.JabasammaMujiExpression:
+		return c.compileJabasammaMuji(node)
+	case *ast.GhumaMujiExpression:
+		return c.compileGhumaMuji(node)
+	case *ast.GhumaMujiEachExpression:
+		return c.compileGhumaMujiEach(node)
+	case *ast.KosisGarMujiExpression:
+		return c.compileKosisGarMuji(node)
+	case *ast.InterpolatedStringExpression:
+		parts := 0
+		for i, text := range node.Texts {
+			if text != "" {
+				c.emitConstant(&object.String{Value: text})
+				parts++
+			}
+			if i < len(node.Values) {
+				if err := c.compile(node.Values[i]); err != nil {
+					return err
+				}
+				parts++
+			}
+		}
+		if parts > math.MaxUint16 {
+			return fmt.Errorf("%s: too many interpolations in string", node.Pos())
+		}
+		c.emit(OpInterpolate, parts)
+	case *ast.ArrayExpression:
+		for _, e := range node.Elements {
+			if err := c.compile(e); err != nil {
+				return err
+			}
+		}
+		if len(node.Elements) > math.MaxUint16 {
+			return fmt.Errorf("%s: too many elements in array literal", node.Pos())
+		}
+		c.emit(OpArray, len(node.Elements))
+	case *ast.TupleExpression:
+		for _, e := range node.Elements {
+			if err := c.compile(e); err != nil {
+				return err
+			}
+		}
+		if len(node.Elements) > math.MaxUint16 {
+			return fmt.Errorf("%s: too many elements in tuple literal", node.Pos())
+		}
+		c.emit(OpTuple, len(node.Elements))
+	case *ast.SetExpression:
+		for _, e := range node.Elements {
+			if err := c.compile(e); err != nil {
+				return err
+			}
+		}
+		if len(node.Elements) > math.MaxUint16 {
+			return fmt.Errorf("%s: too many elements in set literal", node.Pos())
+		}
+		c.emit(OpSet, len(node.Elements))
+	case *ast.HashExpression:
+		for _, k := range node.Keys() {
+			if err := c.compile(k); err != nil {
+				return err
+			}
+			if err := c.compile(node.Pairs[k]); err != nil {
+				return err
+			}
+		}
+		if len(node.Pairs) > math.MaxUint16 {
+			return fmt.Errorf("%s: too many pairs in hashmap literal", node.Pos())
+		}
+		c.emit(OpHash, len(node.Pairs))
+	case *ast.IndexExpression:
+		// the index is evaluated before the operand, as in the tree walker
+		if err := c.compile(node.Index); err != nil {
+			return err
+		}
+		if err := c.compile(node.Operand); err != nil {
+			return err
+		}
+		c.emit(OpIndex)
+	case *ast.SliceExpression:
+		if err := c.compile(node.Operand); err != nil {
+			return err
+		}
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(OpNull)
+			} else if err := c.compile(bound); err != nil {
+				return err
+			}
+		}
+		c.emit(OpSlice)
+	case *ast.KaamGarMujiExpression:
+		return c.compileKaamGarMuji(node)
+	case *ast.CallExpression:
+		// arguments before the callee, as in the tree walker
+		for _, a := range node.Arguments {
+			if err := c.compile(a); err != nil {
+				return err
+			}
+		}
+		if err := c.compile(node.Function); err != nil {
+			return err
+		}
+		if len(node.Arguments) > math.MaxUint8 {
+			return fmt.Errorf("%s: too many arguments", node.Pos())
+		}
+		at := c.emit(OpCall, len(node.Arguments))
+		c.fs.fn.Names[at] = node.Function.String()
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+	return nil
+}
+
+// leaves the value of the last statement on the stack, khali_muji when there are none
+func (c *Compiler) compileStatements(stmts []ast.Statement) error {
+	if len(stmts) == 0 {
+		c.emit(OpNull)
+		return nil
+	}
+	for i, s := range stmts {
+		if err := c.compile(s); err != nil {
+			return err
+		}
+		if i < len(stmts)-1 {
+			c.emit(OpPop)
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileInfix(node *ast.InfixExpression) error {
+	switch node.Operator {
+	case "=":
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		switch left := node.Left.(type) {
+		case *ast.Identifier:
+			c.emitSet(c.resolve(left.Value, false), left.Value)
+		case *ast.IndexExpression:
+			if err := c.compile(left.Operand); err != nil {
+				return err
+			}
+			if err := c.compile(left.Index); err != nil {
+				return err
+			}
+			c.emit(OpSetIndex)
+		default:
+			return fmt.Errorf("%s: left operand of assignment operator is neither identifier nor indexexpression. got=%T", node.Pos(), node.Left)
+		}
+		return nil
+	case "&&", "||":
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		op := OpAnd
+		if node.Operator == "||" {
+			op = OpOr
+		}
+		jump := c.emit(op, 0)
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.patchJump(jump)
+		return nil
+	}
+
+	op, ok := infixOperators[node.Operator]
+	if !ok {
+		return fmt.Errorf("%s: unsupported operator %s", node.Pos(), node.Operator)
+	}
+	if err := c.compile(node.Left); err != nil {
+		return err
+	}
+	if err := c.compile(node.Right); err != nil {
+		return err
+	}
+	c.emit(op)
+	return nil
+}
+
+var infixOperators = map[string]Opcode{
+	"+":  OpAdd,
+	"-":  OpSub,
+	"*":  OpMul,
+	"/":  OpDiv,
+	"%":  OpMod,
+	"==": OpEqual,
+	"!=": OpNotEqual,
+	">":  OpGreater,
+	">=": OpGreaterEqual,
+	"<":  OpLess,
+	"<=": OpLessEqual,
+	"|":  OpUnion,
+	"&":  OpIntersection,
+	"^":  OpSymmetricDifference,
+}
+
+func (c *Compiler) compileYediMuji(node *ast.YediMujiExpression) error {
+	var ends []int
+	branch := func(condition ast.Expression, consequent *ast.BlockStatement) error {
+		if err := c.compile(condition); err != nil {
+			return err
+		}
+		next := c.emit(OpJumpNotTruthy, 0)
+		if err := c.compileStatements(consequent.Statements); err != nil {
+			return err
+		}
+		ends = append(ends, c.emit(OpJump, 0))
+		c.patchJump(next)
+		return nil
+	}
+
+	if err := branch(node.Condition, node.Consequent); err != nil {
+		return err
+	}
+	for _, alt := range node.Alternatives {
+		if err := branch(alt.Condition, alt.Consequent); err != nil {
+			return err
+		}
+	}
+	if node.Fallback != nil {
+		enter := c.enterScope()
+		if err := c.compileStatements(node.Fallback.Statements); err != nil {
+			return err
+		}
+		c.leaveScope(enter)
+	} else {
+		c.emit(OpNull)
+	}
+	for _, end := range ends {
+		c.patchJump(end)
+	}
+	return nil
+}
+
+func (c *Compiler) compileJabasammaMuji(node *ast.JabasammaMujiExpression) error {
+	start := len(c.fs.fn.Instructions)
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(OpJumpNotTruthy, 0)
+
+	l := c.pushLoop()
+	// every iteration gets a fresh scope
+	enter := c.enterScope()
+	if err := c.compileStatements(node.Consequent.Statements); err != nil {
+		return err
+	}
+	c.emit(OpPop)
+	c.leaveScope(enter)
+	c.emit(OpJump, start)
+	c.popLoop(l, start)
+
+	c.patchJump(exit)
+	c.emit(OpNull)
+	return nil
+}
+
+func (c *Compiler) compileGhumaMuji(node *ast.GhumaMujiExpression) error {
+	// one scope for the whole loop, the loop variable included
+	enter := c.enterScope()
+	if node.Initialization != nil {
+		if err := c.compile(node.Initialization); err != nil {
+			return err
+		}
+		c.emit(OpPop)
+	}
+	start := len(c.fs.fn.Instructions)
+	exit := -1
+	if node.Condition != nil {
+		if err := c.compile(node.Condition); err != nil {
+			return err
+		}
+		exit = c.emit(OpJumpNotTruthy, 0)
+	}
+
+	l := c.pushLoop()
+	if err := c.compileStatements(node.Body.Statements); err != nil {
+		return err
+	}
+	c.emit(OpPop)
+	update := len(c.fs.fn.Instructions)
+	if node.Update != nil {
+		if err := c.compile(node.Update); err != nil {
+			return err
+		}
+		c.emit(OpPop)
+	}
+	c.emit(OpJump, start)
+	c.popLoop(l, update)
+	c.leaveScope(enter)
+
+	if exit >= 0 {
+		c.patchJump(exit)
+	}
+	c.emit(OpNull)
+	return nil
+}
+
+func (c *Compiler) compileGhumaMujiEach(node *ast.GhumaMujiEachExpression) error {
+	if err := c.compile(node.Iterable); err != nil {
+		return err
+	}
+	keyed := 0
+	if node.Key != nil {
+		keyed = 1
+	}
+	// errors about the iterable point at it
+	pos := c.pos
+	c.pos = node.Iterable.Pos()
+	c.emit(OpIter, keyed)
+	c.pos = pos
+
+	start := len(c.fs.fn.Instructions)
+	next := c.emit(OpIterNext, keyed, 0)
+
+	l := c.pushLoop()
+	enter := c.enterScope()
+	// the key is on top of the value
+	if node.Key != nil {
+		c.emitDefine(c.declare(node.Key.Value), node.Key.Value)
+		c.emit(OpPop)
+	}
+	c.emitDefine(c.declare(node.Value.Value), node.Value.Value)
+	c.emit(OpPop)
+	if err := c.compileStatements(node.Body.Statements); err != nil {
+		return err
+	}
+	c.emit(OpPop)
+	c.leaveScope(enter)
+	c.emit(OpJump, start)
+	c.popLoop(l, start)
+
+	// OpIterNext jumps here with the iterator still on the stack
+	c.patchUint16(next+2, len(c.fs.fn.Instructions))
+	c.emit(OpPop)
+	c.emit(OpNull)
+	return nil
+}
+
+// body, samat_muji and jasari_pani_muji are laid out as
+//
+//	OpTry catch
+//	body
+//	OpEndTry
+//	OpJump end
+//	catch: [the exception]
+//	OpTry finally
+//	samat_muji
+//	OpEndTry
+//	OpJump end
+//	finally: [the exception]
+//	jasari_pani_muji
+//	OpThrow
+//	end: [the result]
+//	jasari_pani_muji
+//
+// patha_muji, ruk_muji and arko_muji inside body or samat_muji run their own copy of jasari_pani_muji
+func (c *Compiler) compileKosisGarMuji(node *ast.KosisGarMujiExpression) error {
+	fs := c.fs
+	var ends []int
+
+	fs.tries = append(fs.tries, &try{finally: node.Finally, handler: true})
+	handler := c.emit(OpTry, 0)
+	if err := c.compileStatements(node.Body.Statements); err != nil {
+		return err
+	}
+	c.emit(OpEndTry)
+	fs.tries = fs.tries[:len(fs.tries)-1]
+	ends = append(ends, c.emit(OpJump, 0))
+
+	if node.Catch != nil {
+		c.patchJump(handler)
+		// binding the exception is the first step of samat_muji, errors there point at its block
+		pos := c.pos
+		c.pos = node.Catch.Pos()
+		enter := c.enterScope()
+		if node.Param != nil {
+			c.emitDefine(c.declare(node.Param.Value), node.Param.Value)
+		}
+		c.emit(OpPop)
+		c.pos = pos
+		if node.Finally != nil {
+			fs.tries = append(fs.tries, &try{finally: node.Finally, handler: true})
+			handler = c.emit(OpTry, 0)
+		}
+		if err := c.compileStatements(node.Catch.Statements); err != nil {
+			return err
+		}
+		if node.Finally != nil {
+			c.emit(OpEndTry)
+			fs.tries = fs.tries[:len(fs.tries)-1]
+		}
+		c.leaveScope(enter)
+		ends = append(ends, c.emit(OpJump, 0))
+	}
+
+	if node.Finally != nil {
+		c.patchJump(handler)
+		if err := c.compileStatements(node.Finally.Statements); err != nil {
+			return err
+		}
+		c.emit(OpPop)
+		c.emit(OpThrow)
+	}
+
+	for _, end := range ends {
+		c.patchJump(end)
+	}
+	if node.Finally != nil {
+		if err := c.compileStatements(node.Finally.Statements); err != nil {
+			return err
+		}
+		c.emit(OpPop)
+	}
+	return nil
+}
+
+func (c *Compiler) compileKaamGarMuji(node *ast.KaamGarMujiExpression) error {
+	fn := &Function{}
+	c.fs = newFuncState(c.fs, fn)
+	for _, p := range node.Arguments {
+		// a repeated parameter name refers to the last one, like in the tree walker
+		c.fs.scope.names[p.Value] = len(fn.Parameters)
+		fn.Parameters = append(fn.Parameters, p.Value)
+	}
+	fn.NumLocals = len(fn.Parameters)
+	c.hoist(node.Body.Statements)
+	if err := c.compileStatements(node.Body.Statements); err != nil {
+		return err
+	}
+	c.emit(OpReturnValue)
+	c.fs = c.fs.outer
+
+	if err := checkSize(fn, node.Pos()); err != nil {
+		return err
+	}
+	if len(fn.Upvalues) > math.MaxUint8+1 {
+		return fmt.Errorf("%s: function captures too many variables", node.Pos())
+	}
+	if len(fn.Parameters) > math.MaxUint8 {
+		return fmt.Errorf("%s: too many parameters", node.Pos())
+	}
+	c.emit(OpClosure, c.addConstant(fn))
+	return nil
+}
+
+// ruk_muji jumps past the loop, arko_muji to its next iteration
+func (c *Compiler) compileLoopJump(isBreak bool) error {
+	if len(c.fs.loops) == 0 {
+		if isBreak {
+			return fmt.Errorf("%s: ruk_muji used outside of a loop", c.pos)
+		}
+		return fmt.Errorf("%s: arko_muji used outside of a loop", c.pos)
+	}
+	l := c.fs.loops[len(c.fs.loops)-1]
+	if err := c.unwindTries(l.tries); err != nil {
+		return err
+	}
+	jump := c.emit(OpJump, 0)
+	if isBreak {
+		l.breaks = append(l.breaks, jump)
+	} else {
+		l.continues = append(l.continues, jump)
+	}
+	return nil
+}
+
+func (c *Compiler) pushLoop() *loop {
+	l := &loop{tries: len(c.fs.tries)}
+	c.fs.loops = append(c.fs.loops, l)
+	return l
+}
+
+// breaks land on the next instruction
+func (c *Compiler) popLoop(l *loop, next int) {
+	c.fs.loops = c.fs.loops[:len(c.fs.loops)-1]
+	for _, b := range l.breaks {
+		c.patchJump(b)
+	}
+	for _, cont := range l.continues {
+		c.patchUint16(cont+1, next)
+	}
+}
+
+// leaves the kosis_gar_muji blocks above depth, running their jasari_pani_muji
+func (c *Compiler) unwindTries(depth int) error {
+	fs := c.fs
+	tries := fs.tries
+	defer func() { fs.tries = tries }()
+	for i := len(tries) - 1; i >= depth; i-- {
+		t := tries[i]
+		if t.handler {
+			c.emit(OpEndTry)
+		}
+		if t.finally != nil {
+			// a jump inside the copy only has the outer blocks to leave
+			fs.tries = append([]*try(nil), tries[:i]...)
+			if err := c.compileStatements(t.finally.Statements); err != nil {
+				return err
+			}
+			c.emit(OpPop)
+		}
+	}
+	return nil
+}
+
+// declares the functions bound in a scope before compiling it, so they can call each other
+// whatever order they are defined in, as they can in the tree walker
+func (c *Compiler) hoist(stmts []ast.Statement) {
+	for _, s := range stmts {
+		switch s := s.(type) {
+		case *ast.ThoosMujiStatement:
+			if _, ok := s.Value.(*ast.KaamGarMujiExpression); ok {
+				c.declare(s.Name.Value)
+			}
+		case *ast.ExpressionStatement:
+			// blocks that share the scope they are in
+			switch e := s.Expression.(type) {
+			case *ast.YediMujiExpression:
+				c.hoist(e.Consequent.Statements)
+				for _, alt := range e.Alternatives {
+					c.hoist(alt.Consequent.Statements)
+				}
+			case *ast.KosisGarMujiExpression:
+				c.hoist(e.Body.Statements)
+				if e.Finally != nil {
+					c.hoist(e.Finally.Statements)
+				}
+			}
+		}
+	}
+}
+
+/* Scopes */
+// opens a scope and emits the instruction that clears its slots each time it is entered
+// returns where that instruction is, for leaveScope
+func (c *Compiler) enterScope() int {
+	c.fs.scope = &scope{outer: c.fs.scope, names: make(map[string]int), first: c.fs.fn.NumLocals}
+	return c.emit(OpEnterScope, 0, 0)
+}
+
+func (c *Compiler) leaveScope(enter int) {
+	s := c.fs.scope
+	c.patchUint16(enter+1, s.first)
+	c.patchUint16(enter+3, c.fs.fn.NumLocals-s.first)
+	c.fs.scope = s.outer
+}
+
+func (c *Compiler) declare(name string) symbol {
+	s := c.fs.scope
+	if s.global {
+		return symbol{kind: globalSymbol, index: c.globals.Define(name)}
+	}
+	if slot, ok := s.names[name]; ok {
+		return symbol{kind: localSymbol, index: slot}
+	}
+	slot := c.fs.fn.NumLocals
+	c.fs.fn.NumLocals++
+	s.names[name] = slot
+	return symbol{kind: localSymbol, index: slot}
+}
+
+// names that are not declared anywhere yet become globals, they may be defined later
+// and reading them before that is a runtime error, like in the tree walker
+func (c *Compiler) resolve(name string, builtins bool) symbol {
+	if sym, ok := c.fs.resolve(c.globals, name); ok {
+		return sym
+	}
+	if _, ok := eval.LookupBuiltin(name); ok && builtins {
+		return symbol{kind: builtinSymbol}
+	}
+	return symbol{kind: globalSymbol, index: c.globals.Define(name)}
+}
+
+func (fs *funcState) resolve(globals *SymbolTable, name string) (symbol, bool) {
+	for s := fs.scope; s != nil; s = s.outer {
+		if s.global {
+			if index, ok := globals.Resolve(name); ok {
+				return symbol{kind: globalSymbol, index: index}, true
+			}
+			return symbol{}, false
+		}
+		if slot, ok := s.names[name]; ok {
+			return symbol{kind: localSymbol, index: slot}, true
+		}
+	}
+	if fs.outer == nil {
+		return symbol{}, false
+	}
+	sym, ok := fs.outer.resolve(globals, name)
+	if !ok || sym.kind == globalSymbol {
+		return sym, ok
+	}
+	return symbol{kind: freeSymbol, index: fs.addUpvalue(Upvalue{Local: sym.kind == localSymbol, Index: sym.index})}, true
+}
+
+func (fs *funcState) addUpvalue(uv Upvalue) int {
+	if index, ok := fs.upvalues[uv]; ok {
+		return index
+	}
+	index := len(fs.fn.Upvalues)
+	fs.fn.Upvalues = append(fs.fn.Upvalues, uv)
+	fs.upvalues[uv] = index
+	return index
+}
+
+/* Emitting */
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+	fn := c.fs.fn
+	at := len(fn.Instructions)
+	fn.Instructions = append(fn.Instructions, Make(op, operands...)...)
+	if n := len(fn.Positions); n == 0 || fn.Positions[n-1].Pos != c.pos {
+		fn.Positions = append(fn.Positions, Position{Offset: at, Pos: c.pos})
+	}
+	return at
+}
+
+func (c *Compiler) emitGet(sym symbol, name string) {
+	var at int
+	switch sym.kind {
+	case globalSymbol:
+		at = c.emit(OpGetGlobal, sym.index)
+	case localSymbol:
+		at = c.emit(OpGetLocal, sym.index)
+	case freeSymbol:
+		at = c.emit(OpGetFree, sym.index)
+	case builtinSymbol:
+		at = c.emit(OpGetBuiltin, c.addConstant(&object.String{Value: name}))
+	}
+	c.fs.fn.Names[at] = name
+}
+
+func (c *Compiler) emitSet(sym symbol, name string) {
+	var at int
+	switch sym.kind {
+	case globalSymbol:
+		at = c.emit(OpSetGlobal, sym.index)
+	case localSymbol:
+		at = c.emit(OpSetLocal, sym.index)
+	case freeSymbol:
+		at = c.emit(OpSetFree, sym.index)
+	}
+	c.fs.fn.Names[at] = name
+}
+
+func (c *Compiler) emitDefine(sym symbol, name string) {
+	var at int
+	if sym.kind == globalSymbol {
+		at = c.emit(OpDefineGlobal, sym.index)
+	} else {
+		at = c.emit(OpDefineLocal, sym.index)
+	}
+	c.fs.fn.Names[at] = name
+}
+
+func (c *Compiler) emitConstant(obj object.Object) {
+	c.emit(OpConstant, c.addConstant(obj))
+}
+
+// literals with the same value share a constant
+func (c *Compiler) addConstant(obj object.Object) int {
+	switch o := obj.(type) {
+	case *object.Integer:
+		if i, ok := c.ints[o.Value]; ok {
+			return i
+		}
+		c.ints[o.Value] = len(c.constants)
+	case *object.Float:
+		if !math.IsNaN(o.Value) {
+			if i, ok := c.floats[o.Value]; ok {
+				return i
+			}
+			c.floats[o.Value] = len(c.constants)
+		}
+	case *object.String:
+		if i, ok := c.strings[o.Value]; ok {
+			return i
+		}
+		c.strings[o.Value] = len(c.constants)
+	}
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// points the jump at the next instruction
+func (c *Compiler) patchJump(at int) {
+	c.patchUint16(at+1, len(c.fs.fn.Instructions))
+}
+
+func (c *Compiler) patchUint16(at int, value int) {
+	binary.BigEndian.PutUint16(c.fs.fn.Instructions[at:], uint16(value))
+}
@@ -0,0 +1,57 @@
+package compiler
+
+// SymbolTable hands out the slots of global variables
+// the repl keeps one table for the whole session, so every line sees the globals of the lines before it
+type SymbolTable struct {
+	store map[string]int
+	names []string
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]int)}
+}
+
+// returns the existing slot when the name is already defined
+func (s *SymbolTable) Define(name string) int {
+	if index, ok := s.store[name]; ok {
+		return index
+	}
+	index := len(s.names)
+	s.store[name] = index
+	s.names = append(s.names, name)
+	return index
+}
+
+func (s *SymbolTable) Resolve(name string) (int, bool) {
+	index, ok := s.store[name]
+	return index, ok
+}
+
+// names of the globals, indexed by slot
+func (s *SymbolTable) Names() []string {
+	return append([]string(nil), s.names...)
+}
+
+type symbolKind int
+
+const (
+	globalSymbol symbolKind = iota
+	localSymbol
+	freeSymbol
+	builtinSymbol
+)
+
+type symbol struct {
+	kind  symbolKind
+	index int
+}
+
+// scope mirrors an environment of the tree walker
+// blocks only get one where the tree walker encloses the environment: function bodies,
+// loop bodies, nabhae_chikne and samat_muji
+type scope struct {
+	outer  *scope
+	names  map[string]int // local slot of each name declared in the scope
+	first  int            // first slot of the scope, later scopes of the function get higher ones
+	global bool           // the top level of the program
+}
@@ -2,25 +2,32 @@ package eval
 
 import (
 	"fmt"
+	"unicode/utf8"
 
 	"github.com/udeshyadhungana/interprerer/app/object"
+	"github.com/udeshyadhungana/interprerer/app/utils"
 )
 
 var builtins = map[string]*object.Builtin{
 	//common
 	"lambai_muji": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				// characters, the way strings are indexed and walked
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Arr))}
+			case *object.Tuple:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Set:
+				return &object.Integer{Value: int64(arg.Len())}
 			case *object.HashMap:
-				return &object.Integer{Value: int64(len(arg.Pairs))}
+				return &object.Integer{Value: int64(arg.Len())}
 			default:
 				return newError("argument to `lambai_muji` not supported, got %s", args[0].Type())
 			}
@@ -28,22 +35,29 @@ var builtins = map[string]*object.Builtin{
 	},
 	// array operations
 	"khaad_muji": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d want=2", len(args))
 			}
 
-			if args[0].Type() != object.ARRAY_OBJECT {
+			switch a := args[0].(type) {
+			case *object.Array:
+				if err := rt.Allocate(object.ArraySize(1)); err != nil {
+					return err
+				}
+				a.Arr = append(a.Arr, args[1])
+			case *object.Set:
+				if err := AddToSet(rt, a, args[1]); err != nil {
+					return err
+				}
+			default:
 				return newError("argument to `khaad_muji` not supported, got %s", args[0].Type())
 			}
-
-			a := args[0].(*object.Array)
-			a.Arr = append(a.Arr, args[1])
 			return object.NULL
 		},
 	},
 	"udaa_muji": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 && len(args) != 2 {
 				return newError("wrong number of arguments to `udaa_muji`, got=%d want=1 or 2", len(args))
 			}
@@ -52,14 +66,17 @@ var builtins = map[string]*object.Builtin{
 			}
 
 			a := args[0].(*object.Array)
+			if len(a.Arr) == 0 {
+				return newErrorWithKind(object.INDEX_ERROR, "cannot `udaa_muji` from an empty array")
+			}
 			idx := int64(len(a.Arr) - 1)
 			if len(args) == 2 {
 				if args[1].Type() != object.INTEGER_OBJ {
 					return newError("args[1] of `udaa_muji` expected to be an integer object")
 				}
 				idx = args[1].(*object.Integer).Value
-				if idx >= int64(len(a.Arr)) {
-					return newError("cannot `udaa_muji` using index %d, index out of bounds", len(a.Arr))
+				if idx < 0 || idx >= int64(len(a.Arr)) {
+					return newErrorWithKind(object.INDEX_ERROR, "cannot `udaa_muji` using index %d, index out of bounds", idx)
 				}
 			}
 			popped := a.Arr[idx]
@@ -67,25 +84,98 @@ var builtins = map[string]*object.Builtin{
 			return popped
 		},
 	},
+	// set operations
+	// a set of the elements of an array, tuple or set, the keys of a hashmap or the characters of a string
+	"samuha_muji": {
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments to `samuha_muji`. expected 0 or 1, got %d", len(args))
+			}
+			result := object.NewSet(0)
+			if len(args) == 0 {
+				return result
+			}
+			next, err := Iterate(args[0], false)
+			if err != nil {
+				return err
+			}
+			for _, elem, ok := next(); ok; _, elem, ok = next() {
+				if err := AddToSet(rt, result, elem); err != nil {
+					return err
+				}
+			}
+			return result
+		},
+	},
+	// whether a set, array or tuple holds a value, or a hashmap has it as a key
+	"chha_muji": {
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments to `chha_muji`. expected 2, got %d", len(args))
+			}
+			switch c := args[0].(type) {
+			case *object.Set:
+				return utils.GetBoolRef(setHas(c, args[1]))
+			case *object.HashMap:
+				key, err := hashKey(args[1])
+				if err != nil {
+					return object.FALSE
+				}
+				_, ok := c.Get(key)
+				return utils.GetBoolRef(ok)
+			case *object.Array:
+				return utils.GetBoolRef(contains(c.Arr, args[1]))
+			case *object.Tuple:
+				return utils.GetBoolRef(contains(c.Elements, args[1]))
+			default:
+				return newError("argument to `chha_muji` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// whether both arguments are the very same array, hashmap, function and so on, unlike ==
+	"eutai_muji": {
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments to `eutai_muji`. expected 2, got %d", len(args))
+			}
+			return utils.GetBoolRef(identical(args[0], args[1]))
+		},
+	},
 	"bhan_muji": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			for _, a := range args {
-				fmt.Print(a.Inspect())
+				fmt.Fprint(rt.Stdout, a.Inspect())
 			}
-			fmt.Println()
+			fmt.Fprintln(rt.Stdout)
 			return object.NULL
 		},
 	},
+	// reads a line, khali_muji once there is nothing left to read
+	"sun_muji": {
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments to `sun_muji`. expected 0, got %d", len(args))
+			}
+			line, ok := rt.ReadLine()
+			if !ok {
+				return object.NULL
+			}
+			if err := rt.Allocate(object.StringSize(len(line))); err != nil {
+				return err
+			}
+			return &object.String{Value: line}
+		},
+	},
 	"abs": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments to `abs`. expected 1, got %d", len(args))
 			}
 			aa := args[0]
 			switch a := aa.(type) {
-			case *object.Integer:
-				if a.Value < 0 {
-					return &object.Integer{Value: -a.Value}
+			case *object.Integer, *object.BigInteger:
+				if compareIntegers(a, &object.Integer{Value: 0}) < 0 {
+					return evalMinusPrefixOperatorExpression(a)
 				}
 				return a
 			case *object.Float:
@@ -99,3 +189,12 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 }
+
+func contains(elems []object.Object, value object.Object) bool {
+	for _, e := range elems {
+		if evalEQ(e, value) == object.TRUE {
+			return true
+		}
+	}
+	return false
+}
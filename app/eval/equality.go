@@ -0,0 +1,95 @@
+package eval
+
+import (
+	"github.com/udeshyadhungana/interprerer/app/object"
+	"github.com/udeshyadhungana/interprerer/app/utils"
+)
+
+// numbers, booleans and strings are compared by value, arrays, tuples, hashmaps and sets by what they hold
+// values of different types are never equal, and anything else, like a function, is only equal to itself
+func evalEQ(left object.Object, right object.Object) *object.Boolean {
+	return utils.GetBoolRef(equal(left, right, nil))
+}
+
+// seen holds the pairs of containers being compared further up, it is made once the first one is reached
+func equal(left object.Object, right object.Object, seen map[[2]object.Object]bool) bool {
+	if left.Type() != right.Type() {
+		return false
+	}
+	switch l := left.(type) {
+	case *object.Integer:
+		return l.Value == right.(*object.Integer).Value
+	case *object.BigInteger:
+		return l.Value.Cmp(right.(*object.BigInteger).Value) == 0
+	case *object.Float:
+		return l.Value == right.(*object.Float).Value
+	case *object.Boolean:
+		return l.Value == right.(*object.Boolean).Value
+	case *object.String:
+		return l.Value == right.(*object.String).Value
+	case *object.Set:
+		// elements are hashable, so their keys tell them apart exactly
+		return setsEqual(l, right.(*object.Set))
+	case *object.Array, *object.Tuple, *object.HashMap:
+		if left == right {
+			return true
+		}
+		pair := [2]object.Object{left, right}
+		if seen[pair] {
+			// a cycle, the comparison that is already under way decides
+			return true
+		}
+		if seen == nil {
+			seen = map[[2]object.Object]bool{}
+		}
+		seen[pair] = true
+		switch l := left.(type) {
+		case *object.Array:
+			return elementsEqual(l.Arr, right.(*object.Array).Arr, seen)
+		case *object.Tuple:
+			return elementsEqual(l.Elements, right.(*object.Tuple).Elements, seen)
+		default:
+			return hashMapsEqual(left.(*object.HashMap), right.(*object.HashMap), seen)
+		}
+	default:
+		return left == right
+	}
+}
+
+func elementsEqual(l []object.Object, r []object.Object, seen map[[2]object.Object]bool) bool {
+	if len(l) != len(r) {
+		return false
+	}
+	for i := range l {
+		if !equal(l[i], r[i], seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// the same keys with equal values, in any order
+func hashMapsEqual(l *object.HashMap, r *object.HashMap, seen map[[2]object.Object]bool) bool {
+	if l.Len() != r.Len() {
+		return false
+	}
+	for _, pair := range l.Pairs() {
+		key, _ := hashKey(pair.Key)
+		other, ok := r.Get(key)
+		if !ok || !equal(pair.Value, other.Value, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// whether left and right are the same value
+// numbers, booleans, strings and khali_muji cannot be changed, so they are the same whenever they are equal
+func identical(left object.Object, right object.Object) bool {
+	switch left.(type) {
+	case *object.Integer, *object.BigInteger, *object.Float, *object.Boolean, *object.String, *object.Null:
+		return evalEQ(left, right) == object.TRUE
+	default:
+		return left == right
+	}
+}
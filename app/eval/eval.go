@@ -1,14 +1,43 @@
 package eval
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/udeshyadhungana/interprerer/app/ast"
 	"github.com/udeshyadhungana/interprerer/app/object"
 	"github.com/udeshyadhungana/interprerer/app/utils"
 )
 
+// like Eval, but resets the limits of env's runtime and stops the program with a LimitError once ctx is done
+func EvalWithContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	env.Runtime().Start(ctx)
+	return Eval(node, env)
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	rt := env.Runtime()
+	if err := rt.Step(); err != nil {
+		err.Pos = node.Pos()
+		return err
+	}
+	// every environment being evaluated in is live, the memory limit measures from them
+	if rt.PushRoot(env) {
+		defer rt.PopRoot()
+	}
+	result := evalNode(node, env)
+	// the innermost node that produced an error decides its position
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return result
+}
+
+func evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -18,24 +47,45 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalYediMujiStatement(node, env)
 	case *ast.PathaMujiStatement:
 		return evalPathaMujiStatement(node.Value, env)
+	case *ast.RukMujiStatement:
+		return object.BREAK
+	case *ast.ArkoMujiStatement:
+		return object.CONTINUE
+	case *ast.FyakMujiStatement:
+		return evalFyakMujiStatement(node.Value, env)
+	case *ast.KosisGarMujiExpression:
+		return evalKosisGarMujiExpression(node, env)
 	case *ast.ThoosMujiStatement:
 		return evalThoosMujiStatement(node.Name, node.Value, env)
 	case *ast.BlockStatement:
 		return evalStatements(node.Statements, env)
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
 	case *ast.FloatLiteral:
 		return &object.Float{Value: node.Value}
 	case *ast.StringExpression:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedStringExpression:
+		return evalInterpolatedStringExpression(node, env)
 	case *ast.ArrayExpression:
 		return evalArrayExpression(node, env)
+	case *ast.TupleExpression:
+		return evalTupleExpression(node, env)
+	case *ast.SetExpression:
+		return evalSetExpression(node, env)
 	case *ast.IndexExpression:
 		return evalIndexExpression(node, env)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	case *ast.JabasammaMujiExpression:
 		return evalJabasammaMujiExpression(node.Condition, node.Consequent, env)
 	case *ast.GhumaMujiExpression:
 		return evalGhumaMujiExpression(node.Initialization, node.Condition, node.Update, node.Body, env)
+	case *ast.GhumaMujiEachExpression:
+		return evalGhumaMujiEachExpression(node, env)
 	case *ast.HashExpression:
 		return evalHashExpression(node, env)
 	case *ast.Boolean:
@@ -51,6 +101,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalCallExpression(node, env)
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
+		if isInterrupt(right) {
+			return right
+		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
 		/* If assignment operator, evaluate the right and assign to right */
@@ -58,11 +111,21 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if node.Operator == "=" {
 			return evalAssignment(node.Left, node.Right, env)
 		}
+		/* && and || only evaluate the right side when they need to */
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node.Left, node.Operator, node.Right, env)
+		}
 		left := Eval(node.Left, env)
+		if isInterrupt(left) {
+			return left
+		}
 		right := Eval(node.Right, env)
-		return evalInfixExpression(left, node.Operator, right)
+		if isInterrupt(right) {
+			return right
+		}
+		return evalInfixExpression(env.Runtime(), left, node.Operator, right)
 	}
-	fmt.Printf("FATAL: Eval() does not implement %s node\n", node.String())
+	fmt.Fprintf(env.Runtime().Stderr, "FATAL: Eval() does not implement %s node\n", node.String())
 	return nil
 }
 
@@ -86,17 +149,22 @@ func evalStatements(stmts []ast.Statement, env *object.Environment) object.Objec
 
 	for _, statement := range stmts {
 		result = Eval(statement, env)
-
-		if result != nil {
-			rt := result.Type()
-			if rt == object.PATHA_MUJI_OBJ || rt == object.GALAT_MUJI_OBJ {
-				return result
-			}
+		if isInterrupt(result) {
+			return result
 		}
 	}
 	return result
 }
 
+// the vm shares these with the tree walker, so both engines agree on what an operator does
+func Prefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+func Infix(rt *object.Runtime, left object.Object, operator string, right object.Object) object.Object {
+	return evalInfixExpression(rt, left, operator, right)
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
@@ -124,7 +192,12 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	switch r := right.(type) {
 	case *object.Integer:
+		if r.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(toBig(r)))
+		}
 		return &object.Integer{Value: -r.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Neg(r.Value))
 	case *object.Float:
 		return &object.Float{Value: -r.Value}
 	default:
@@ -133,55 +206,42 @@ func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 }
 
 /* Infix begin */
-func evalArithmetic(left object.Object, operator string, right object.Object) object.Object {
+func evalArithmetic(rt *object.Runtime, left object.Object, operator string, right object.Object) object.Object {
+	if _, ok := left.(*object.Set); ok && operator == "-" {
+		return evalSetOperation(rt, left, operator, right)
+	}
 	if left.Type() == right.Type() && operator == "+" {
 		switch l := left.(type) {
 		case *object.String:
 			r := right.(*object.String)
+			if err := rt.Allocate(object.StringSize(len(l.Value) + len(r.Value))); err != nil {
+				return err
+			}
 			return &object.String{Value: l.Value + r.Value}
 		case *object.Array:
 			r := right.(*object.Array)
-			return &object.Array{Arr: append(l.Arr, r.Arr...)}
+			if err := rt.Allocate(object.ArraySize(len(l.Arr) + len(r.Arr))); err != nil {
+				return err
+			}
+			// a new array, the operands may have room to spare that other arrays would then share
+			arr := make([]object.Object, len(l.Arr)+len(r.Arr))
+			copy(arr, l.Arr)
+			copy(arr[len(l.Arr):], r.Arr)
+			return &object.Array{Arr: arr}
 		}
 	}
 	if !areBothNumbers(left, right) {
 		return newError("unsupported operation %s %s %s", left.Type(), operator, right.Type())
 	}
-	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
-		l := left.(*object.Integer).Value
-		r := right.(*object.Integer).Value
-		var res object.Integer
-		switch operator {
-		case "+":
-			res.Value = l + r
-		case "-":
-			res.Value = l - r
-		case "*":
-			res.Value = l * r
-		case "/":
-			res.Value = l / r
-		case "%":
-			res.Value = l % r
-		default:
-			return newError("unsupported operation %s %s %s", left.Type(), operator, right.Type())
-		}
-		return &res
-	}
-	var leftVal, rightVal float64
-	var result object.Float
-
-	if left.Type() == object.INTEGER_OBJ {
-		leftVal = float64(left.(*object.Integer).Value)
-	} else {
-		leftVal = left.(*object.Float).Value
+	if isInteger(left) && isInteger(right) {
+		return evalIntegerArithmetic(rt, left, operator, right)
 	}
+	leftVal, rightVal := toFloat(left), toFloat(right)
+	var result object.Float
 
-	if right.Type() == object.INTEGER_OBJ {
-		rightVal = float64(right.(*object.Integer).Value)
-	} else {
-		rightVal = right.(*object.Float).Value
+	if rt.StrictFloats && rightVal == 0 && (operator == "/" || operator == "%") {
+		return divisionByZero(operator)
 	}
-
 	switch operator {
 	case "+":
 		result.Value = leftVal + rightVal
@@ -191,66 +251,24 @@ func evalArithmetic(left object.Object, operator string, right object.Object) ob
 		result.Value = leftVal * rightVal
 	case "/":
 		result.Value = leftVal / rightVal
+	case "%":
+		// the sign of the dividend, like integers
+		result.Value = math.Mod(leftVal, rightVal)
 	default:
 		return newError("unsupported operation %s %s %s", left.Type(), operator, right.Type())
 	}
 	return &result
 }
 
-func evalEQ(left object.Object, right object.Object) *object.Boolean {
-	if left.Type() != right.Type() {
-		return object.FALSE
-	}
-	switch l := left.(type) {
-	case *object.Integer:
-		r := right.(*object.Integer)
-		if l.Value == r.Value {
-			return object.TRUE
-		}
-		return object.FALSE
-	case *object.Float:
-		r := right.(*object.Float)
-		if l.Value == r.Value {
-			return object.TRUE
-		}
-		return object.FALSE
-	case *object.Boolean:
-		r := right.(*object.Boolean)
-		if l.Value == r.Value {
-			return object.TRUE
-		}
-		return object.FALSE
-	default:
-		// we can either go with checking if they are same objects
-		// or take the python's approach of checking each element
-		// we do neither ¯\_(ツ)_/¯
-		return object.FALSE
-	}
-}
-
 func evalLT(left object.Object, right object.Object) object.Object {
 	// ensure both of them are either float or int
 	if !areBothNumbers(left, right) {
 		return newError("cannot use '<' operator for %s", left.Type())
 	}
-	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
-		l := left.(*object.Integer).Value
-		r := right.(*object.Integer).Value
-		return utils.GetBoolRef(l < r)
-	}
-	var leftVal, rightVal float64
-	if left.Type() == object.INTEGER_OBJ {
-		leftVal = float64(left.(*object.Integer).Value)
-	} else {
-		leftVal = (left.(*object.Float).Value)
-	}
-
-	if right.Type() == object.INTEGER_OBJ {
-		rightVal = float64(right.(*object.Integer).Value)
-	} else {
-		rightVal = right.(*object.Float).Value
+	if isInteger(left) && isInteger(right) {
+		return utils.GetBoolRef(compareIntegers(left, right) < 0)
 	}
-
+	leftVal, rightVal := toFloat(left), toFloat(right)
 	return utils.GetBoolRef(leftVal < rightVal)
 }
 
@@ -258,31 +276,19 @@ func evalGT(left object.Object, right object.Object) object.Object {
 	if !areBothNumbers(left, right) {
 		return newError("cannot use '>' operator for %s", left.Type())
 	}
-	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
-		l := left.(*object.Integer).Value
-		r := right.(*object.Integer).Value
-		return utils.GetBoolRef(l > r)
-	}
-	var leftVal, rightVal float64
-	if left.Type() == object.INTEGER_OBJ {
-		leftVal = float64(left.(*object.Integer).Value)
-	} else {
-		leftVal = (left.(*object.Float).Value)
-	}
-
-	if right.Type() == object.INTEGER_OBJ {
-		rightVal = float64(right.(*object.Integer).Value)
-	} else {
-		rightVal = right.(*object.Float).Value
+	if isInteger(left) && isInteger(right) {
+		return utils.GetBoolRef(compareIntegers(left, right) > 0)
 	}
-
+	leftVal, rightVal := toFloat(left), toFloat(right)
 	return utils.GetBoolRef(leftVal > rightVal)
 }
 
-func evalInfixExpression(left object.Object, operator string, right object.Object) object.Object {
+func evalInfixExpression(rt *object.Runtime, left object.Object, operator string, right object.Object) object.Object {
 	switch operator {
 	case "+", "-", "*", "/", "%":
-		return evalArithmetic(left, operator, right)
+		return evalArithmetic(rt, left, operator, right)
+	case "|", "&", "^":
+		return evalSetOperation(rt, left, operator, right)
 	case "==":
 		return evalEQ(left, right)
 	case "!=":
@@ -332,22 +338,45 @@ func evalInfixExpression(left object.Object, operator string, right object.Objec
 	}
 }
 
+// returns the operand that decided the result, like javascript does
+func evalLogicalExpression(left ast.Expression, operator string, right ast.Expression, env *object.Environment) object.Object {
+	l := Eval(left, env)
+	if isInterrupt(l) {
+		return l
+	}
+	if operator == "&&" && !utils.IsTruthy(l) {
+		return l
+	}
+	if operator == "||" && utils.IsTruthy(l) {
+		return l
+	}
+	return Eval(right, env)
+}
+
 func areBothNumbers(left object.Object, right object.Object) bool {
-	return (left.Type() == object.INTEGER_OBJ || left.Type() == object.FLOAT_OBJ) &&
-		(right.Type() == object.INTEGER_OBJ || right.Type() == object.FLOAT_OBJ)
+	return (isInteger(left) || left.Type() == object.FLOAT_OBJ) &&
+		(isInteger(right) || right.Type() == object.FLOAT_OBJ)
 }
 
 /* End Infix */
 
 func evalYediMujiStatement(yediMujiExpr *ast.YediMujiExpression, env *object.Environment) object.Object {
-	if isConditionTrue(yediMujiExpr.Condition, env) {
+	ok, err := isConditionTrue(yediMujiExpr.Condition, env)
+	if err != nil {
+		return err
+	}
+	if ok {
 		return evalStatements(yediMujiExpr.Consequent.Statements, env)
 	}
 
 	// Evaluate alternatives now
 	if yediMujiExpr.Alternatives != nil {
 		for _, alt := range yediMujiExpr.Alternatives {
-			if isConditionTrue(alt.Condition, env) {
+			ok, err := isConditionTrue(alt.Condition, env)
+			if err != nil {
+				return err
+			}
+			if ok {
 				return evalStatements(alt.Consequent.Statements, env)
 			}
 		}
@@ -361,78 +390,250 @@ func evalYediMujiStatement(yediMujiExpr *ast.YediMujiExpression, env *object.Env
 }
 
 func evalJabasammaMujiExpression(condition ast.Node, consequent ast.Node, env *object.Environment) object.Object {
-	var result object.Object
+	body, ok := consequent.(*ast.BlockStatement)
+	if !ok {
+		return newError("body of jaba samma muji must be a block statement")
+	}
 	newEnv := object.NewEnclosedEnvironment(env)
-	for isConditionTrue(condition, newEnv) {
-		body, ok := consequent.(*ast.BlockStatement)
+	for {
+		ok, err := isConditionTrue(condition, newEnv)
+		if err != nil {
+			return err
+		}
 		if !ok {
-			return newError("body of jaba samma muji must be a block statement")
+			break
+		}
+		result := Eval(body, object.NewEnclosedEnvironment(newEnv))
+		if stop, value := loopSignal(result); stop {
+			return value
 		}
-		newEnv := object.NewEnclosedEnvironment(newEnv)
-		result = Eval(body, newEnv)
 	}
-	return result
+	return object.NULL
 }
 
 func evalGhumaMujiExpression(initialization ast.Node, condition ast.Node, update ast.Node, body ast.Node, env *object.Environment) object.Object {
 	newEnv := object.NewEnclosedEnvironment(env)
 	init := Eval(initialization, newEnv)
-	if init.Type() == object.GALAT_MUJI_OBJ {
+	if isInterrupt(init) {
 		return init
 	}
-	for isConditionTrue(condition, newEnv) {
-		// check condition
+	for {
+		ok, err := isConditionTrue(condition, newEnv)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
 		result := Eval(body, newEnv)
-		if result.Type() == object.GALAT_MUJI_OBJ || result.Type() == object.PATHA_MUJI_OBJ {
-			return result
+		if stop, value := loopSignal(result); stop {
+			return value
+		}
+		// the update is part of the loop, a ruk_muji in it ends this loop
+		if stop, value := loopSignal(Eval(update, newEnv)); stop {
+			return value
+		}
+	}
+	return object.NULL
+}
+
+func evalGhumaMujiEachExpression(node *ast.GhumaMujiEachExpression, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isInterrupt(iterable) {
+		return iterable
+	}
+	next, err := Iterate(iterable, node.Key != nil)
+	if err != nil {
+		err.Pos = node.Iterable.Pos()
+		return err
+	}
+	for {
+		key, value, ok := next()
+		if !ok {
+			break
+		}
+		loopEnv := object.NewEnclosedEnvironment(env)
+		if node.Key != nil {
+			loopEnv.Set(node.Key.Value, key)
 		}
-		up := Eval(update, newEnv)
-		if up.Type() == object.GALAT_MUJI_OBJ {
+		loopEnv.Set(node.Value.Value, value)
+		if stop, result := loopSignal(Eval(node.Body, loopEnv)); stop {
 			return result
 		}
 	}
 	return object.NULL
 }
 
-// true should be checked against err == nil && bool == true
-// the only case where bool = false and err == nil is when Eval() does not implement case for the node
-func isConditionTrue(condition ast.Node, env *object.Environment) bool {
+// hands out one key and value per call, ok is false once there is nothing left
+type Iterator func() (key object.Object, value object.Object, ok bool)
+
+// arrays, tuples and sets give index and element, hashmaps key and value, strings index and character
+// without keyed, the value is the element, the key or the character
+func Iterate(iterable object.Object, keyed bool) (Iterator, *object.Error) {
+	i := 0
+	switch it := iterable.(type) {
+	case *object.Array:
+		// elements appended by the body are not visited
+		elems := it.Arr
+		return func() (object.Object, object.Object, bool) {
+			if i >= len(elems) {
+				return nil, nil, false
+			}
+			i++
+			return &object.Integer{Value: int64(i - 1)}, elems[i-1], true
+		}, nil
+	case *object.Tuple:
+		return func() (object.Object, object.Object, bool) {
+			if i >= len(it.Elements) {
+				return nil, nil, false
+			}
+			i++
+			return &object.Integer{Value: int64(i - 1)}, it.Elements[i-1], true
+		}, nil
+	case *object.Set:
+		// as with arrays, elements added by the body are not visited
+		elems := it.Elements()
+		return func() (object.Object, object.Object, bool) {
+			if i >= len(elems) {
+				return nil, nil, false
+			}
+			i++
+			return &object.Integer{Value: int64(i - 1)}, elems[i-1], true
+		}, nil
+	case *object.HashMap:
+		// as with arrays, keys added by the body are not visited
+		pairs := it.Pairs()
+		return func() (object.Object, object.Object, bool) {
+			if i >= len(pairs) {
+				return nil, nil, false
+			}
+			i++
+			if !keyed {
+				return nil, pairs[i-1].Key, true
+			}
+			return pairs[i-1].Key, pairs[i-1].Value, true
+		}, nil
+	case *object.String:
+		rest := it.Value
+		return func() (object.Object, object.Object, bool) {
+			if rest == "" {
+				return nil, nil, false
+			}
+			r, size := utf8.DecodeRuneInString(rest)
+			rest = rest[size:]
+			i++
+			return &object.Integer{Value: int64(i - 1)}, &object.String{Value: string(r)}, true
+		}, nil
+	default:
+		return nil, newError("cannot iterate over %s", iterable.Type())
+	}
+}
+
+// decides what a loop does with the result of one run of its body
+// ruk_muji ends the loop, errors and patha_muji also leave the enclosing function
+// arko_muji and ordinary values carry on with the next iteration
+func loopSignal(result object.Object) (bool, object.Object) {
+	if result == nil {
+		return false, nil
+	}
+	switch result.Type() {
+	case object.RUK_MUJI_OBJ:
+		return true, object.NULL
+	case object.GALAT_MUJI_OBJ, object.PATHA_MUJI_OBJ:
+		return true, result
+	default:
+		return false, nil
+	}
+}
+
+// an error while evaluating the condition, or a ruk_muji, arko_muji or patha_muji in it,
+// is returned instead of being treated as false
+func isConditionTrue(condition ast.Node, env *object.Environment) (bool, object.Object) {
 	cc := Eval(condition, env)
 	if cc == nil {
-		return false
+		return false, nil
 	}
-	if isError(cc) {
-		return false
+	if isInterrupt(cc) {
+		return false, cc
 	}
-	return utils.IsTruthy(cc)
+	return utils.IsTruthy(cc), nil
 }
 
 func evalPathaMujiStatement(value ast.Node, env *object.Environment) object.Object {
 	val := Eval(value, env)
-	if isError(val) {
+	if isInterrupt(val) {
 		return val
 	}
 	return &object.Return{
-		Value: Eval(value, env),
+		Value: val,
+	}
+}
+
+func evalFyakMujiStatement(value ast.Node, env *object.Environment) object.Object {
+	val := Eval(value, env)
+	if isInterrupt(val) {
+		return val
+	}
+	return Throw(val)
+}
+
+// the error raised by `fyak_muji val`
+// throwing a caught error rethrows it, keeping its kind and stack
+func Throw(val object.Object) *object.Error {
+	if e, ok := val.(*object.Exception); ok {
+		return e.Err
 	}
+	return &object.Error{Message: val.Inspect(), Kind: object.THROWN_ERROR, Value: val}
+}
+
+func evalKosisGarMujiExpression(node *ast.KosisGarMujiExpression, env *object.Environment) object.Object {
+	result := Eval(node.Body, env)
+
+	if err, ok := result.(*object.Error); ok && node.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		if node.Param != nil {
+			catchEnv.Set(node.Param.Value, &object.Exception{Err: err})
+		}
+		result = Eval(node.Catch, catchEnv)
+	}
+
+	if node.Finally != nil {
+		// an error or patha_muji inside jasari_pani_muji replaces whatever happened before
+		fin := Eval(node.Finally, env)
+		if fin != nil && (fin.Type() == object.GALAT_MUJI_OBJ || fin.Type() == object.PATHA_MUJI_OBJ) {
+			return fin
+		}
+	}
+	if result == nil {
+		return object.NULL
+	}
+	return result
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := LookupBuiltin(node.Value); ok {
 		return builtin
 	}
 	return newError("identifier not found: %s", node.Value)
 }
 
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
 func evalThoosMujiStatement(name *ast.Identifier, value ast.Expression, env *object.Environment) object.Object {
 	if name != nil {
 		v := Eval(value, env)
-		if v.Type() == object.GALAT_MUJI_OBJ {
+		if isInterrupt(v) {
 			return v
 		}
+		if f, ok := v.(*object.KaamGar); ok && f.Name == "" {
+			f.Name = name.Value
+		}
 		return env.Set(name.Value, v)
 	}
 	return newError("identifier is nil")
@@ -443,7 +644,7 @@ func evalAssignment(name ast.Expression, value ast.Expression, env *object.Envir
 		return newError("left operand of assignment operator is nil")
 	}
 	v := Eval(value, env)
-	if v.Type() == object.GALAT_MUJI_OBJ {
+	if isInterrupt(v) {
 		return v
 	}
 	switch n := name.(type) {
@@ -464,27 +665,36 @@ func evalAssignment(name ast.Expression, value ast.Expression, env *object.Envir
 
 func evalAssignmentForIndexExpression(ie *ast.IndexExpression, value object.Object, env *object.Environment) object.Object {
 	operand := Eval(ie.Operand, env)
+	if isInterrupt(operand) {
+		return operand
+	}
 	index := Eval(ie.Index, env)
+	if isInterrupt(index) {
+		return index
+	}
+	return SetIndex(env.Runtime(), operand, index, value)
+}
+
+// operand[index] = value
+func SetIndex(rt *object.Runtime, operand object.Object, index object.Object, value object.Object) object.Object {
 	switch operand.Type() {
 	case object.ARRAY_OBJECT:
-		if index.Type() != object.INTEGER_OBJ {
-			return newError("cannot index an array using %s", index.Type())
-		}
 		a := operand.(*object.Array)
-		i := index.(*object.Integer)
-		if i.Value >= int64(len(a.Arr)) {
-			return newError("array index out of bounds")
+		i, err := sequenceIndex(index, len(a.Arr), "array")
+		if err != nil {
+			return err
 		}
-		a.Arr[i.Value] = value
+		a.Arr[i] = value
 		return value
 	case object.HASHMAP_OBJECT:
-		if index.Type() != object.STRING {
-			return newError("cannot index a hashmap using %s", index.Type())
+		if err := SetHashPair(rt, operand.(*object.HashMap), index, value); err != nil {
+			return err
 		}
-		h := operand.(*object.HashMap)
-		i := index.(*object.String)
-		h.Pairs[i.Value] = value
 		return value
+	case object.STRING:
+		return newError("strings cannot be changed, build a new one instead")
+	case object.TUPLE_OBJ:
+		return newError("tuples cannot be changed, build a new one instead")
 	default:
 		return newError("only arrays and hashmaps can be indexed")
 	}
@@ -503,38 +713,52 @@ func evalCallExpression(name *ast.CallExpression, env *object.Environment) objec
 	var evaluatedArgs []*object.Object
 	for _, v := range name.Arguments {
 		e := Eval(v, env)
-		if isError(e) {
+		if isInterrupt(e) {
 			return e
 		}
 		evaluatedArgs = append(evaluatedArgs, &e)
 	}
 
-	fn := evalIdentifier(&ast.Identifier{Value: name.Function.TokenLiteral()}, env)
-	switch fn.Type() {
-	case object.GALAT_MUJI_OBJ:
-		// might be a function expression itself
-		if kgr, ok := name.Function.(*ast.KaamGarMujiExpression); ok {
-			fn = evalKaamGarMujiExpression(kgr, env)
-		} else {
-			return newError("Cannot apply: %s", name.Function.String())
-		}
-	case object.BUILTIN_OBJECT:
-		f := fn.(*object.Builtin)
-		return evalBuiltin(f, evaluatedArgs)
+	// the callee can be any expression: f(x), arr[0](x), makeAdder(2)(3)
+	fn := Eval(name.Function, env)
+	if isInterrupt(fn) {
+		return fn
 	}
-
-	if f, ok := fn.(*object.KaamGar); ok {
-		return evalUserDefinedCall(f, evaluatedArgs)
+	switch f := fn.(type) {
+	case *object.Builtin:
+		return evalBuiltin(f, evaluatedArgs, env.Runtime())
+	case *object.KaamGar:
+		result := evalUserDefinedCall(f, evaluatedArgs)
+		if err, ok := result.(*object.Error); ok {
+			err.Stack = append(err.Stack, newFrame(f, name, evaluatedArgs))
+		}
+		return result
 	}
-	return newError("cannot apply %s; not a function or a builtin", name.Function.TokenLiteral())
+	return newError("cannot apply %s; not a function or a builtin", name.Function.String())
 }
 
-func evalBuiltin(b *object.Builtin, args []*object.Object) object.Object {
+func evalBuiltin(b *object.Builtin, args []*object.Object, rt *object.Runtime) object.Object {
 	converted := make([]object.Object, len(args))
 	for i, v := range args {
 		converted[i] = *v
 	}
-	return b.Fn(converted...)
+	return b.Fn(rt, converted...)
+}
+
+// calls a function or builtin from go, with env providing the runtime for builtins
+func Call(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	pointers := make([]*object.Object, len(args))
+	for i := range args {
+		pointers[i] = &args[i]
+	}
+	switch f := fn.(type) {
+	case *object.Builtin:
+		return evalBuiltin(f, pointers, env.Runtime())
+	case *object.KaamGar:
+		return evalUserDefinedCall(f, pointers)
+	default:
+		return newError("cannot apply %s; not a function or a builtin", fn.Type())
+	}
 }
 
 func evalUserDefinedCall(f *object.KaamGar, args []*object.Object) object.Object {
@@ -543,13 +767,18 @@ func evalUserDefinedCall(f *object.KaamGar, args []*object.Object) object.Object
 		return newError("arguments length mismatch")
 	}
 
-	// set it for function's environment
-	f.Env = object.NewEnclosedEnvironment(f.Env)
+	// every call gets its own environment, chained to the one the function was defined in
+	// closures made during the call keep it alive after the call returns
+	callEnv := object.NewEnclosedEnvironment(f.Env)
 	for i, v := range f.Parameters {
-		f.Env.Set(v.Value, *args[i])
+		callEnv.Set(v.Value, *args[i])
+	}
+	rt := callEnv.Runtime()
+	if err := rt.Enter(); err != nil {
+		return err
 	}
-	result := Apply(f)
-	f.Env = f.Env.PopStack()
+	result := Apply(f, callEnv)
+	rt.Leave()
 	return result
 }
 
@@ -557,59 +786,280 @@ func evalArrayExpression(a *ast.ArrayExpression, env *object.Environment) object
 	var result object.Array
 	for i := range a.Elements {
 		evaluated := Eval(a.Elements[i], env)
-		if evaluated.Type() == object.GALAT_MUJI_OBJ {
+		if isInterrupt(evaluated) {
 			return evaluated
 		}
 		result.Arr = append(result.Arr, evaluated)
 	}
+	if err := env.Runtime().Allocate(object.ArraySize(len(result.Arr))); err != nil {
+		return err
+	}
 	return &result
 }
 
+func evalTupleExpression(t *ast.TupleExpression, env *object.Environment) object.Object {
+	elements := make([]object.Object, len(t.Elements))
+	for i, e := range t.Elements {
+		elements[i] = Eval(e, env)
+		if isInterrupt(elements[i]) {
+			return elements[i]
+		}
+	}
+	if err := env.Runtime().Allocate(object.ArraySize(len(elements))); err != nil {
+		return err
+	}
+	return &object.Tuple{Elements: elements}
+}
+
+func evalInterpolatedStringExpression(s *ast.InterpolatedStringExpression, env *object.Environment) object.Object {
+	parts := make([]object.Object, 0, len(s.Texts)+len(s.Values))
+	for i, text := range s.Texts {
+		parts = append(parts, &object.String{Value: text})
+		if i < len(s.Values) {
+			evaluated := Eval(s.Values[i], env)
+			if isInterrupt(evaluated) {
+				return evaluated
+			}
+			parts = append(parts, evaluated)
+		}
+	}
+	return Interpolate(env.Runtime(), parts)
+}
+
+// joins parts into one string, strings as they are and everything else the way it is printed
+func Interpolate(rt *object.Runtime, parts []object.Object) object.Object {
+	var out strings.Builder
+	for _, part := range parts {
+		out.WriteString(part.Inspect())
+	}
+	if err := rt.Allocate(object.StringSize(out.Len())); err != nil {
+		return err
+	}
+	return &object.String{Value: out.String()}
+}
+
 func evalIndexExpression(a *ast.IndexExpression, env *object.Environment) object.Object {
 	idxEvaluated := Eval(a.Index, env)
-	if idxEvaluated.Type() == object.GALAT_MUJI_OBJ {
+	if isInterrupt(idxEvaluated) {
 		return idxEvaluated
 	}
 	operand := Eval(a.Operand, env)
+	if isInterrupt(operand) {
+		return operand
+	}
+	return Index(operand, idxEvaluated)
+}
+
+// operand[idxEvaluated]
+func Index(operand object.Object, idxEvaluated object.Object) object.Object {
 	switch operand.Type() {
 	case object.ARRAY_OBJECT:
-		if idxEvaluated.Type() != object.INTEGER_OBJ {
-			return newError("array index must be an integer, got %s", idxEvaluated.Type())
-		}
 		arr := operand.(*object.Array)
-		idx := idxEvaluated.(*object.Integer)
-		return arr.Arr[idx.Value]
+		i, err := sequenceIndex(idxEvaluated, len(arr.Arr), "array")
+		if err != nil {
+			return err
+		}
+		return arr.Arr[i]
+	case object.STRING:
+		// strings are indexed by character, like ghuma_muji walks them
+		runes := []rune(operand.(*object.String).Value)
+		i, err := sequenceIndex(idxEvaluated, len(runes), "string")
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: string(runes[i])}
+	case object.TUPLE_OBJ:
+		tuple := operand.(*object.Tuple)
+		i, err := sequenceIndex(idxEvaluated, len(tuple.Elements), "tuple")
+		if err != nil {
+			return err
+		}
+		return tuple.Elements[i]
 	case object.HASHMAP_OBJECT:
+		key, err := hashKey(idxEvaluated)
+		if err != nil {
+			return err
+		}
+		pair, ok := operand.(*object.HashMap).Get(key)
+		if !ok {
+			return object.NULL
+		}
+		return pair.Value
+	case object.EXCEPTION_OBJ:
 		if idxEvaluated.Type() != object.STRING {
-			return newError("hashmap index must be a string, got %s", idxEvaluated.Type())
+			return newError("exception index must be a string, got %s", idxEvaluated.Type())
 		}
-		hmap := operand.(*object.HashMap)
-		idx := idxEvaluated.(*object.String)
-		return hmap.Pairs[idx.Value]
-	case object.GALAT_MUJI_OBJ:
-		return operand
+		field := idxEvaluated.(*object.String)
+		val, ok := operand.(*object.Exception).Field(field.Value)
+		if !ok {
+			return newError("exception has no field %q", field.Value)
+		}
+		return val
 	default:
 		return newError("cannot index %s", operand.Type())
 	}
 }
 
+// the position index refers to in a sequence of length n, a negative one counts from the end
+func sequenceIndex(index object.Object, n int, what string) (int, *object.Error) {
+	if !isInteger(index) {
+		return 0, newError("%s index must be an integer, got %s", what, index.Type())
+	}
+	// a BigInteger is always out of range
+	if i, ok := index.(*object.Integer); ok {
+		v := i.Value
+		if v < 0 {
+			v += int64(n)
+		}
+		if v >= 0 && v < int64(n) {
+			return int(v), nil
+		}
+	}
+	return 0, newErrorWithKind(object.INDEX_ERROR, "%s index %s out of range for length %d", what, index.Inspect(), n)
+}
+
+func evalSliceExpression(s *ast.SliceExpression, env *object.Environment) object.Object {
+	operand := Eval(s.Operand, env)
+	if isInterrupt(operand) {
+		return operand
+	}
+	bounds := [2]object.Object{object.NULL, object.NULL}
+	for i, bound := range []ast.Expression{s.Low, s.High} {
+		if bound == nil {
+			continue
+		}
+		bounds[i] = Eval(bound, env)
+		if isInterrupt(bounds[i]) {
+			return bounds[i]
+		}
+	}
+	return Slice(env.Runtime(), operand, bounds[0], bounds[1])
+}
+
+// operand[low:high] of an array, tuple or string, a copy of the elements from low up to high
+// a missing bound is khali_muji, negative bounds count from the end and bounds past either end are clamped
+func Slice(rt *object.Runtime, operand object.Object, low object.Object, high object.Object) object.Object {
+	switch o := operand.(type) {
+	case *object.Array:
+		from, to, err := sliceBounds(low, high, len(o.Arr))
+		if err != nil {
+			return err
+		}
+		if err := rt.Allocate(object.ArraySize(to - from)); err != nil {
+			return err
+		}
+		return &object.Array{Arr: append([]object.Object(nil), o.Arr[from:to]...)}
+	case *object.Tuple:
+		from, to, err := sliceBounds(low, high, len(o.Elements))
+		if err != nil {
+			return err
+		}
+		if err := rt.Allocate(object.ArraySize(to - from)); err != nil {
+			return err
+		}
+		return &object.Tuple{Elements: append([]object.Object(nil), o.Elements[from:to]...)}
+	case *object.String:
+		runes := []rune(o.Value)
+		from, to, err := sliceBounds(low, high, len(runes))
+		if err != nil {
+			return err
+		}
+		str := string(runes[from:to])
+		if err := rt.Allocate(object.StringSize(len(str))); err != nil {
+			return err
+		}
+		return &object.String{Value: str}
+	default:
+		return newError("cannot slice %s", operand.Type())
+	}
+}
+
+func sliceBounds(low object.Object, high object.Object, n int) (int, int, *object.Error) {
+	bound := func(b object.Object, missing int) (int, *object.Error) {
+		if b == object.NULL {
+			return missing, nil
+		}
+		if !isInteger(b) {
+			return 0, newError("slice bounds must be integers, got %s", b.Type())
+		}
+		if i, ok := b.(*object.Integer); ok && i.Value >= -int64(n) && i.Value <= int64(n) {
+			if i.Value < 0 {
+				return n + int(i.Value), nil
+			}
+			return int(i.Value), nil
+		}
+		// past one of the ends
+		if compareIntegers(b, &object.Integer{Value: 0}) < 0 {
+			return 0, nil
+		}
+		return n, nil
+	}
+	from, err := bound(low, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := bound(high, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	if to < from {
+		to = from
+	}
+	return from, to, nil
+}
+
 func evalHashExpression(node *ast.HashExpression, env *object.Environment) object.Object {
-	pairs := node.Pairs
-	result := object.HashMap{Pairs: make(map[string]object.Object)}
-	for k, v := range pairs {
+	result := object.NewHashMap(len(node.Pairs))
+	for _, k := range node.Keys() {
 		key := Eval(k, env)
-		if key.Type() != object.STRING {
-			return newError("key must be a string")
+		if isInterrupt(key) {
+			return key
+		}
+		val := Eval(node.Pairs[k], env)
+		if isInterrupt(val) {
+			return val
+		}
+		if err := SetHashPair(env.Runtime(), result, key, val); err != nil {
+			return err
 		}
-		keyStr := key.(*object.String)
-		val := Eval(v, env)
-		result.Pairs[keyStr.Value] = val
 	}
-	return &result
+	return result
 }
 
-func Apply(f *object.KaamGar) object.Object {
-	res := Eval(f.Body, f.Env)
+// h[key] = val, a new key goes after the ones already in h
+func SetHashPair(rt *object.Runtime, h *object.HashMap, key object.Object, val object.Object) *object.Error {
+	hk, err := hashKey(key)
+	if err != nil {
+		return err
+	}
+	if pair, ok := h.Get(hk); ok {
+		// the key it was added with stays
+		h.Set(hk, object.HashPair{Key: pair.Key, Value: val})
+		return nil
+	}
+	if err := rt.Allocate(object.PairSize(len(hk.Value))); err != nil {
+		return err
+	}
+	h.Set(hk, object.HashPair{Key: key, Value: val})
+	return nil
+}
+
+// what key is stored under in a hashmap
+func hashKey(key object.Object) (object.HashKey, *object.Error) {
+	h, ok := key.(object.Hashable)
+	if !ok {
+		return object.HashKey{}, newError("%s cannot be a hashmap key", key.Type())
+	}
+	hk, ok := h.HashKey()
+	if !ok {
+		return object.HashKey{}, newError("%s cannot be a hashmap key", key.Inspect())
+	}
+	return hk, nil
+}
+
+// runs the body of f in env, the environment of one call
+func Apply(f *object.KaamGar, env *object.Environment) object.Object {
+	res := Eval(f.Body, env)
 
 	if returnValue, ok := res.(*object.Return); ok {
 		return returnValue.Value
@@ -618,13 +1068,37 @@ func Apply(f *object.KaamGar) object.Object {
 }
 
 /* Utils */
+// describes a call for the stack trace of an error passing through it
+func newFrame(f *object.KaamGar, call *ast.CallExpression, args []*object.Object) object.Frame {
+	name := f.Name
+	if name == "" {
+		name = call.Function.String()
+	}
+	values := make([]object.Object, len(args))
+	for i, a := range args {
+		values[i] = *a
+	}
+	return object.NewFrame(name, call.Pos(), values)
+}
+
 func newError(format string, a ...any) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return newErrorWithKind(object.RUNTIME_ERROR, format, a...)
 }
 
-func isError(obj object.Object) bool {
-	if obj != nil {
-		return obj.Type() == object.GALAT_MUJI_OBJ
+func newErrorWithKind(kind string, format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+}
+
+// errors, patha_muji, ruk_muji and arko_muji stop the expression they happen in
+// and are handed on until the function, loop or kosis_gar_muji they belong to deals with them
+func isInterrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.GALAT_MUJI_OBJ, object.PATHA_MUJI_OBJ, object.RUK_MUJI_OBJ, object.ARKO_MUJI_OBJ:
+		return true
+	default:
+		return false
 	}
-	return false
 }
@@ -1,15 +1,24 @@
-package eval
+package eval_test
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/udeshyadhungana/interprerer/app/ast"
+	"github.com/udeshyadhungana/interprerer/app/compiler"
+	"github.com/udeshyadhungana/interprerer/app/eval"
 	"github.com/udeshyadhungana/interprerer/app/lexer"
 	"github.com/udeshyadhungana/interprerer/app/object"
 	"github.com/udeshyadhungana/interprerer/app/parser"
+	"github.com/udeshyadhungana/interprerer/app/token"
+	"github.com/udeshyadhungana/interprerer/app/vm"
 )
 
-func testEval(input string) object.Object {
+// runs the program with the tree walker and with the vm, which must agree on the result
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
 	l := lexer.NewLexer(input)
 	p := parser.NewParser(l)
 	program := p.ParseProgram()
@@ -20,7 +29,42 @@ func testEval(input string) object.Object {
 		}
 		return nil
 	}
-	return Eval(program, env)
+	evaluated := eval.Eval(program, env)
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Errorf("compiler error for %q: %s", input, err)
+		return evaluated
+	}
+	run := vm.New(c.Bytecode()).Run()
+	if describe(run) != describe(evaluated) {
+		t.Errorf("vm disagrees with the tree walker for %q\neval: %s\nvm:   %s", input, describe(evaluated), describe(run))
+	}
+	return evaluated
+}
+
+// what two engines have to agree on, hashmaps are printed in insertion order
+func describe(obj object.Object) string {
+	switch o := obj.(type) {
+	case nil:
+		return "<nil>"
+	case *object.Error:
+		return fmt.Sprintf("%s %s %v", o.Kind, o.Inspect(), o.Stack)
+	case *object.Array:
+		elems := make([]string, len(o.Arr))
+		for i, e := range o.Arr {
+			elems[i] = describe(e)
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case *object.HashMap:
+		var pairs []string
+		for _, pair := range o.Pairs() {
+			pairs = append(pairs, describe(pair.Key)+": "+describe(pair.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	default:
+		return fmt.Sprintf("%s %s", obj.Type(), obj.Inspect())
+	}
 }
 
 func TestEvalIntegerStatement(t *testing.T) {
@@ -44,14 +88,37 @@ func TestEvalIntegerStatement(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"0xFF", 255},
+		{"0b1010", 10},
+		{"0o755", 493},
+		{"1_000_000", 1000000},
+		{"0x10 + 0b1", 17},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
 
+func TestFloatLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1e-9", 1e-9},
+		{"6.02E23", 6.02e23},
+		{"2.5e+3", 2500},
+		{"1_000.5", 1000.5},
+		{"1e3 + 1", 1001},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalFloatObject(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -67,7 +134,7 @@ func TestEvalFloatObject(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		if evaluated.Type() != object.FLOAT_OBJ {
 			t.Fatalf("expected float, got %T", evaluated)
 		}
@@ -115,6 +182,118 @@ func testStringObject(t *testing.T, obj object.Object, expected string) bool {
 	return true
 }
 
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // type and value, as describe prints them
+	}{
+		{"9223372036854775807 + 1", "BIG_INTEGER 9223372036854775808"},
+		{"-9223372036854775807 - 2", "BIG_INTEGER -9223372036854775809"},
+		{"4294967296 * 4294967296", "BIG_INTEGER 18446744073709551616"},
+		{"-9223372036854775807 - 1", "INTEGER -9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "BIG_INTEGER 9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "BIG_INTEGER 9223372036854775808"},
+		{"(-9223372036854775807 - 1) % -1", "INTEGER 0"},
+		{"99999999999999999999", "BIG_INTEGER 99999999999999999999"},
+		{"0xFFFFFFFFFFFFFFFFFF", "BIG_INTEGER 4722366482869645213695"},
+		{"-9223372036854775808", "INTEGER -9223372036854775808"},
+		// back to an integer once it fits
+		{"(9223372036854775807 + 1) - 1", "INTEGER 9223372036854775807"},
+		{"99999999999999999999 / 99999999999999999999", "INTEGER 1"},
+		{"99999999999999999999 % 10", "INTEGER 9"},
+		{"-99999999999999999999 / 7", "BIG_INTEGER -14285714285714285714"},
+		{"-99999999999999999999 % 7", "INTEGER -1"},
+		{"99999999999999999999 * 2.0", "FLOAT 200000000000000000000.000000"},
+		{"99999999999999999999 > 1", "BOOLEAN sacho_muji"},
+		{"-99999999999999999999 < 1", "BOOLEAN sacho_muji"},
+		{"99999999999999999999 >= 99999999999999999999", "BOOLEAN sacho_muji"},
+		{"99999999999999999999 <= 99999999999999999998", "BOOLEAN jhut_muji"},
+		{"99999999999999999999 > 1.5", "BOOLEAN sacho_muji"},
+		{"99999999999999999999 == 99999999999999999999", "BOOLEAN sacho_muji"},
+		{"99999999999999999999 != 99999999999999999998", "BOOLEAN sacho_muji"},
+		{"99999999999999999999 == 1", "BOOLEAN jhut_muji"},
+		{"abs(-99999999999999999999)", "BIG_INTEGER 99999999999999999999"},
+		{"abs(-9223372036854775807 - 1)", "BIG_INTEGER 9223372036854775808"},
+		{"\"${99999999999999999999 + 1}\"", "STRING 100000000000000000000"},
+		{
+			`
+			thoos_muji factorial = kaam_gar_muji(n) {
+				yedi_muji (n < 2) { patha_muji 1; }
+				patha_muji n * factorial(n - 1);
+			};
+			factorial(25)
+			`,
+			"BIG_INTEGER 15511210043330985984000000",
+		},
+		{
+			`
+			thoos_muji a = 0;
+			thoos_muji b = 1;
+			ghuma_muji (thoos_muji i = 0; i < 100; i = i + 1) {
+				thoos_muji next = a + b;
+				a = b;
+				b = next;
+			}
+			a
+			`,
+			"BIG_INTEGER 354224848179261915075",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if got := describe(evaluated); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "division by zero"},
+		{"5 % 0", "modulo by zero"},
+		{"99999999999999999999 / 0", "division by zero"},
+		{"99999999999999999999 % (1 - 1)", "modulo by zero"},
+		{"thoos_muji f = kaam_gar_muji(x) { 10 / x }; f(0)", "division by zero"},
+	}
+
+	for _, tt := range errorTests {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != object.ZERO_DIVISION_ERROR || errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error. got=%s %q", tt.input, errObj.Kind, errObj.Message)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`kosis_gar_muji { 1 / 0; } samat_muji (e) { e["kind"] + ": " + e["message"]; }`, "ZeroDivisionError: division by zero"},
+		{"1.0 / 0", "+Inf"},
+		{"-1 / 0.0", "-Inf"},
+		{"0.0 / 0", "NaN"},
+		{"5.5 % 0.0", "NaN"},
+		{"5.5 % 2", "1.500000"},
+		{"-5.5 % 2", "-1.500000"},
+		{"7 % 2.5", "2.000000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s. got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestEvalBoolStatement(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -142,7 +321,7 @@ func TestEvalBoolStatement(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testBoolObject(t, evaluated, tt.expected)
 	}
 }
@@ -160,6 +339,44 @@ func testBoolObject(t *testing.T, obj object.Object, expected bool) bool {
 	return true
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"sacho_muji && sacho_muji", true},
+		{"sacho_muji && jhut_muji", false},
+		{"jhut_muji || sacho_muji", true},
+		{"jhut_muji || jhut_muji", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"5 && 6", 6},
+		{"5 || 6", 5},
+		{"jhut_muji || 6", 6},
+		{"jhut_muji && 6", false},
+		{`thoos_muji x = [3]; x != jhut_muji && x[0] > 1`, true},
+		// the right side is not evaluated, so the undefined identifier is never looked up
+		{"jhut_muji && undefined", false},
+		{"sacho_muji || undefined", true},
+		{`thoos_muji n = 0;
+		thoos_muji bump = kaam_gar_muji() { n = n + 1; patha_muji sacho_muji; };
+		jhut_muji && bump();
+		sacho_muji || bump();
+		sacho_muji && bump();
+		n;`, 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBoolObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -174,7 +391,7 @@ func TestBangOperator(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testBoolObject(t, evaluated, tt.expected)
 	}
 }
@@ -198,7 +415,7 @@ func TestYediMujiExpressions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 
 		if ok {
@@ -237,7 +454,7 @@ func TestPathaMujiStatements(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 
 		if ok {
@@ -290,10 +507,20 @@ func TestErrorHandling(t *testing.T) {
 			"foobar;",
 			"identifier not found: foobar",
 		},
+		{
+			// a recursive call does not see the locals of the call that made it
+			`thoos_muji f = kaam_gar_muji(n) {
+				yedi_muji (n == 0) { patha_muji secret; }
+				thoos_muji secret = n;
+				patha_muji f(n - 1);
+			};
+			f(1);`,
+			"identifier not found: secret",
+		},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		errObj, ok := evaluated.(*object.Error)
 		if !ok {
 			t.Errorf("no error object returned. got=%T(%+v)",
@@ -308,6 +535,192 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"5 + sacho_muji;", "1:1"},
+		{"thoos_muji x = 1;\nx + foobar;", "2:5"},
+		{
+			`thoos_muji f = kaam_gar_muji(a) {
+				patha_muji a + sacho_muji;
+			};
+			f(1);`,
+			"2:16",
+		},
+		{"lambai_muji(1, 2);", "1:1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position. expected=%s, got=%s", tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	input := `thoos_muji inner = kaam_gar_muji(x, y) {
+	patha_muji x + y;
+};
+thoos_muji rec = kaam_gar_muji(n) {
+	yedi_muji (n == 0) {
+		patha_muji inner(n, sacho_muji);
+	}
+	patha_muji rec(n - 1);
+};
+rec(2);`
+
+	expected := []string{
+		"6:14: inner(0, sacho_muji)",
+		"8:13: rec(0)",
+		"8:13: rec(1)",
+		"10:1: rec(2)",
+	}
+
+	evaluated := testEval(t, input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong number of frames. expected=%d, got=%d", len(expected), len(errObj.Stack))
+	}
+	for i, frame := range expected {
+		if errObj.Stack[i].String() != frame {
+			t.Errorf("frame[%d] wrong. expected=%q, got=%q", i, frame, errObj.Stack[i].String())
+		}
+	}
+
+	traceback := errObj.Traceback()
+	if !strings.HasPrefix(traceback, "Traceback (most recent call last):\n  10:1: rec(2)\n") {
+		t.Errorf("traceback does not start with the outermost call. got=%q", traceback)
+	}
+	if !strings.HasSuffix(traceback, "\nERROR: 2:13: unsupported operation INTEGER + BOOLEAN") {
+		t.Errorf("traceback does not end with the error. got=%q", traceback)
+	}
+
+	// recursion collapses even though every call has different arguments
+	evaluated = testEval(t, `thoos_muji f = kaam_gar_muji(n) { yedi_muji (n == 0) { patha_muji 1 / 0; } patha_muji f(n - 1); }; f(500);`)
+	errObj, ok = evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	expectedTraceback := "Traceback (most recent call last):\n" +
+		"  1:100: f(500)\n" +
+		"  1:87: f(499)\n" +
+		"  [previous line repeated 499 more times]\n" +
+		"ERROR: 1:67: division by zero"
+	if traceback := errObj.Traceback(); traceback != expectedTraceback {
+		t.Errorf("wrong traceback. expected=%q, got=%q", expectedTraceback, traceback)
+	}
+}
+
+func TestKosisGarMujiExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`kosis_gar_muji { 1; } samat_muji (e) { 2; }`, 1},
+		{`kosis_gar_muji { fyak_muji 5; } samat_muji (e) { e["value"]; }`, 5},
+		{`kosis_gar_muji { fyak_muji "boom"; } samat_muji (e) { e["message"]; }`, "boom"},
+		{`kosis_gar_muji { fyak_muji "boom"; } samat_muji (e) { e["kind"]; }`, "ThrownError"},
+		{`kosis_gar_muji { 1 + sacho_muji; } samat_muji (e) { e["kind"]; }`, "RuntimeError"},
+		{`kosis_gar_muji { 1 + sacho_muji; } samat_muji (e) { e["message"]; }`, "unsupported operation INTEGER + BOOLEAN"},
+		{`kosis_gar_muji { udaa_muji([]); } samat_muji (e) { e["kind"]; }`, "IndexError"},
+		{`kosis_gar_muji { udaa_muji([1], 3); } samat_muji { "caught"; }`, "caught"},
+		{
+			`thoos_muji x = 0;
+			kosis_gar_muji { fyak_muji 1; } samat_muji (e) { x = x + 1; } jasari_pani_muji { x = x + 10; }
+			x;`,
+			11,
+		},
+		{
+			`thoos_muji x = 0;
+			kosis_gar_muji { 1; } jasari_pani_muji { x = 10; }
+			x;`,
+			10,
+		},
+		{
+			`thoos_muji f = kaam_gar_muji() {
+				kosis_gar_muji { patha_muji 1; } jasari_pani_muji { patha_muji 2; }
+			};
+			f();`,
+			2,
+		},
+		{
+			`thoos_muji x = 0;
+			thoos_muji f = kaam_gar_muji() {
+				kosis_gar_muji { patha_muji 1; } jasari_pani_muji { x = 5; }
+			};
+			f() + x;`,
+			6,
+		},
+		{
+			`thoos_muji inner = kaam_gar_muji() { fyak_muji "deep"; };
+			thoos_muji outer = kaam_gar_muji() { inner(); };
+			kosis_gar_muji { outer(); } samat_muji (e) { lambai_muji(e["stack"]); }`,
+			2,
+		},
+		{
+			`kosis_gar_muji {
+				kosis_gar_muji { fyak_muji "inner"; } samat_muji (e) { fyak_muji e; }
+			} samat_muji (e) { e["message"]; }`,
+			"inner",
+		},
+		{
+			`thoos_muji down = kaam_gar_muji(n) { down(n + 1); };
+			kosis_gar_muji { down(0); } samat_muji (e) { e["kind"]; }`,
+			"LimitError",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestUncaughtErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedKind    string
+	}{
+		{`fyak_muji "oops";`, "oops", object.THROWN_ERROR},
+		{`kosis_gar_muji { fyak_muji "oops"; } jasari_pani_muji { 1; }`, "oops", object.THROWN_ERROR},
+		{`kosis_gar_muji { 1; } samat_muji (e) { 2; } jasari_pani_muji { fyak_muji "late"; }`, "late", object.THROWN_ERROR},
+		{`kosis_gar_muji { fyak_muji 1; } samat_muji (e) { e["nope"]; }`, `exception has no field "nope"`, object.RUNTIME_ERROR},
+		{`thoos_muji f = kaam_gar_muji() { f(); }; f();`, "call depth limit of 10000 exceeded", object.LIMIT_ERROR},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind. expected=%q, got=%q", tt.expectedKind, errObj.Kind)
+		}
+	}
+}
+
 /* TestThoosMuji */
 func TestThoosMujiStatements(t *testing.T) {
 	tests := []struct {
@@ -321,7 +734,7 @@ func TestThoosMujiStatements(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
 	}
 }
 
@@ -338,7 +751,7 @@ func TestFunctionApplication(t *testing.T) {
 		{"kaam_gar_muji(x) { x; }(5)", 5},
 	}
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
 	}
 }
 
@@ -361,11 +774,127 @@ func TestRecursion(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.program)
+		evaluated := testEval(t, tt.program)
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
 
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			`thoos_muji makeCounter = kaam_gar_muji() {
+				thoos_muji count = 0;
+				patha_muji kaam_gar_muji() {
+					count = count + 1;
+					patha_muji count;
+				};
+			};
+			thoos_muji one = makeCounter();
+			thoos_muji other = makeCounter();
+			one();
+			one();
+			other();
+			one() * 10 + other();`,
+			32,
+		},
+		{
+			`thoos_muji makeAdder = kaam_gar_muji(x) {
+				kaam_gar_muji(y) { x + y; };
+			};
+			thoos_muji addTwo = makeAdder(2);
+			thoos_muji addTen = makeAdder(10);
+			addTwo(3) * 100 + addTen(1);`,
+			511,
+		},
+		{
+			// every call of f captures its own n
+			`thoos_muji fns = [];
+			thoos_muji f = kaam_gar_muji(n) {
+				khaad_muji(fns, kaam_gar_muji() { n; });
+				yedi_muji (n > 0) {
+					f(n - 1);
+				}
+			};
+			f(2);
+			thoos_muji first = fns[0];
+			thoos_muji last = fns[2];
+			first() * 10 + last();`,
+			20,
+		},
+		{
+			`thoos_muji isEven = kaam_gar_muji(n) {
+				yedi_muji (n == 0) { patha_muji sacho_muji; }
+				patha_muji isOdd(n - 1);
+			};
+			thoos_muji isOdd = kaam_gar_muji(n) {
+				yedi_muji (n == 0) { patha_muji jhut_muji; }
+				patha_muji isEven(n - 1);
+			};
+			yedi_muji (isEven(10) && isOdd(7) && !isEven(3)) { 1; } nabhae_chikne { 0; }`,
+			1,
+		},
+		{
+			`thoos_muji outer = kaam_gar_muji(n) {
+				thoos_muji ping = kaam_gar_muji(k) {
+					yedi_muji (k == 0) { patha_muji n; }
+					patha_muji pong(k - 1);
+				};
+				thoos_muji pong = kaam_gar_muji(k) {
+					yedi_muji (k == 0) { patha_muji -n; }
+					patha_muji ping(k - 1);
+				};
+				patha_muji ping(5);
+			};
+			outer(4);`,
+			-4,
+		},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestCallExpressionCallee(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`thoos_muji fns = [kaam_gar_muji(x) { x * 2; }]; fns[0](5);`, 10},
+		{`thoos_muji makeAdder = kaam_gar_muji(x) { kaam_gar_muji(y) { x + y; }; }; makeAdder(2)(3);`, 5},
+		{`thoos_muji m = {"f": kaam_gar_muji(x) { x - 1; }}; m["f"](8);`, 7},
+		{`thoos_muji m = {"len": lambai_muji}; m["len"]("four");`, 4},
+		{
+			`thoos_muji apply = kaam_gar_muji(f, x) { f(x); };
+			thoos_muji twice = kaam_gar_muji(f) { kaam_gar_muji(x) { f(f(x)); }; };
+			apply(twice(kaam_gar_muji(x) { x * 3; }), 2);`,
+			18,
+		},
+		{`kaam_gar_muji() { kaam_gar_muji() { 6; }; }()();`, 6},
+		{`thoos_muji x = 5; x(1);`, "cannot apply x; not a function or a builtin"},
+		{`[1, 2][0](1);`, "cannot apply [1, 2][0]; not a function or a builtin"},
+		{`nope(1);`, "identifier not found: nope"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -374,6 +903,11 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`lambai_muji("")`, 0},
 		{`lambai_muji("four")`, 4},
 		{`lambai_muji("hello world")`, 11},
+		{`lambai_muji("tab\tnewline\n")`, 12},
+		{`lambai_muji("\"\\")`, 2},
+		{"lambai_muji(`\n\t\tab\n\t\tc\n\t`)", 4},
+		{`lambai_muji("नमस्ते")`, 6},
+		{`thoos_muji n = 0; ghuma_muji (thoos_muji c : "héllo") { n = n + 1; } n - lambai_muji("héllo")`, 0},
 		{`lambai_muji(1)`, "argument to `lambai_muji` not supported, got INTEGER"},
 		{`lambai_muji("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`lambai_muji([1,2,4])`, 3},
@@ -413,7 +947,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
@@ -460,10 +994,16 @@ func TestIndexEval(t *testing.T) {
 			`,
 			"bar",
 		},
+		{`[1, 2, 3][-1]`, 3},
+		{`[1, 2, 3][-3]`, 1},
+		{`thoos_muji x = [1, 2, 3]; x[-1] = 9; x[2]`, 9},
+		{`"muji"[0]`, "m"},
+		{`"muji"[-1]`, "i"},
+		{`"नमस्ते"[1]`, "म"},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
@@ -475,6 +1015,91 @@ func TestIndexEval(t *testing.T) {
 	}
 }
 
+func TestIndexErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		kind     string
+		expected string
+	}{
+		{`[1, 2, 3][3]`, object.INDEX_ERROR, "array index 3 out of range for length 3"},
+		{`[1, 2, 3][-4]`, object.INDEX_ERROR, "array index -4 out of range for length 3"},
+		{`[][0]`, object.INDEX_ERROR, "array index 0 out of range for length 0"},
+		{`[1][99999999999999999999]`, object.INDEX_ERROR, "array index 99999999999999999999 out of range for length 1"},
+		{`thoos_muji x = [1]; x[1] = 2;`, object.INDEX_ERROR, "array index 1 out of range for length 1"},
+		{`thoos_muji x = [1]; x[-2] = 2;`, object.INDEX_ERROR, "array index -2 out of range for length 1"},
+		{`"abc"[5]`, object.INDEX_ERROR, "string index 5 out of range for length 3"},
+		{`[1]["a"]`, object.RUNTIME_ERROR, "array index must be an integer, got STRING"},
+		{`"abc"[1.0]`, object.RUNTIME_ERROR, "string index must be an integer, got FLOAT"},
+		{`thoos_muji s = "abc"; s[0] = "x";`, object.RUNTIME_ERROR, "strings cannot be changed, build a new one instead"},
+		{`[1, 2]["a":]`, object.RUNTIME_ERROR, "slice bounds must be integers, got STRING"},
+		{`{"a": 1}[0:1]`, object.RUNTIME_ERROR, "cannot slice HASHMAP"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.kind || errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error. expected=%s %q, got=%s %q", tt.input, tt.kind, tt.expected, errObj.Kind, errObj.Message)
+		}
+	}
+}
+
+func TestSliceEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+		{`[1, 2, 3, 4][:-1]`, "[1, 2, 3]"},
+		{`[1, 2, 3, 4][2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][:]`, "[1, 2, 3, 4]"},
+		{`[1, 2, 3, 4][-2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][3:1]`, "[]"},
+		{`[1, 2, 3, 4][-100:100]`, "[1, 2, 3, 4]"},
+		{`[1, 2, 3, 4][99999999999999999999:]`, "[]"},
+		{`[1, 2, 3, 4][:-99999999999999999999]`, "[]"},
+		{`thoos_muji n = 1; [1, 2, 3, 4][n + 1:n * 4]`, "[3, 4]"},
+		{`"muji lang"[2:]`, "ji lang"},
+		{`"muji lang"[:4]`, "muji"},
+		{`"muji lang"[-4:-1]`, "lan"},
+		{`"नमस्ते"[1:3]`, "मस"},
+		{`"abc"[5:]`, ""},
+		// a slice is a copy
+		{`thoos_muji a = [1, 2, 3]; thoos_muji b = a[:]; b[0] = 9; a`, "[1, 2, 3]"},
+		{`thoos_muji a = [[1], 2]; a[:1][0][0]`, "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s. got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestArrayConcatenation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2] + [3]`, "[1, 2, 3]"},
+		{`[] + []`, "[]"},
+		{`thoos_muji a = [1, 2, 3]; thoos_muji b = a + [4]; thoos_muji c = a + [5]; b`, "[1, 2, 3, 4]"},
+		{`thoos_muji a = [1]; khaad_muji(a, 2); thoos_muji b = a + [3]; b[0] = 9; a`, "[1, 2]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s. got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 // too lazy to write different test cases, i combined them
 func TestJabasammaMujiAndAssignment(t *testing.T) {
 	tests := []struct {
@@ -519,7 +1144,7 @@ func TestJabasammaMujiAndAssignment(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		if !testIntegerObject(t, evaluated, tt.expected) {
 			t.Fatalf("failed to test JabasammaMujiExpression")
 		}
@@ -533,22 +1158,196 @@ func TestGhumaMujiExpression(t *testing.T) {
 	}{
 		{
 			`
+			thoos_muji count = 0;
 			ghuma_muji(thoos_muji i = 0; i < 100; i = i + 1) {
-				sacho_muji;
+				count = count + 1;
 			}
-			i;
+			count;
 			`,
 			100,
 		},
 	}
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		if !testIntegerObject(t, evaluated, tt.expected) {
 			t.Fatalf("failed to test JabasammaMujiExpression")
 		}
 	}
 }
 
+func TestGhumaMujiEachExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`thoos_muji s = 0; ghuma_muji (thoos_muji x : [1, 2, 3]) { s = s + x; } s;`, 6},
+		{`thoos_muji s = 0; ghuma_muji (thoos_muji i, x : [5, 6, 7]) { s = s + i * x; } s;`, 20},
+		{`thoos_muji s = ""; ghuma_muji (thoos_muji k : {"b": 1, "a": 2}) { s = s + k; } s;`, "ba"},
+		{`thoos_muji s = 0; ghuma_muji (thoos_muji k, v : {"b": 1, "a": 2}) { s = s + v; } s;`, 3},
+		{`thoos_muji s = ""; ghuma_muji (thoos_muji c : "नमस्ते") { s = c + s; } s;`, "ेत्समन"},
+		{`thoos_muji n = 0; ghuma_muji (thoos_muji i, c : "héllo") { n = i; } n;`, 4},
+		{`thoos_muji n = 0; ghuma_muji (thoos_muji x : []) { n = n + 1; } n;`, 0},
+		{
+			`thoos_muji s = 0;
+			ghuma_muji (thoos_muji x : [1, 2, 3, 4, 5, 6]) {
+				yedi_muji (x % 2 == 0) { arko_muji; }
+				yedi_muji (x > 4) { ruk_muji; }
+				s = s + x;
+			}
+			s;`,
+			4,
+		},
+		{
+			`thoos_muji arr = [1, 2];
+			ghuma_muji (thoos_muji x : arr) { khaad_muji(arr, x); }
+			lambai_muji(arr);`,
+			4,
+		},
+		{`ghuma_muji (thoos_muji x : [1]) { x; } x;`, "identifier not found: x"},
+		{`ghuma_muji (thoos_muji x : 5) { x; }`, "cannot iterate over INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, err.Message)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestLoopControl(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			`thoos_muji i = 0;
+			jaba_samma_muji (sacho_muji) {
+				i = i + 1;
+				yedi_muji (i == 5) { ruk_muji; }
+			}
+			i;`,
+			5,
+		},
+		{
+			`thoos_muji sum = 0;
+			ghuma_muji (thoos_muji i = 0; i < 10; i = i + 1) {
+				yedi_muji (i % 2 == 1) { arko_muji; }
+				sum = sum + i;
+			}
+			sum;`,
+			20,
+		},
+		{
+			`thoos_muji sum = 0;
+			thoos_muji i = 0;
+			jaba_samma_muji (i < 10) {
+				i = i + 1;
+				yedi_muji (i % 2 == 1) { arko_muji; }
+				sum = sum + i;
+			}
+			sum;`,
+			30,
+		},
+		{
+			// ruk_muji only leaves the innermost loop
+			`thoos_muji count = 0;
+			ghuma_muji (thoos_muji i = 0; i < 3; i = i + 1) {
+				ghuma_muji (thoos_muji j = 0; j < 10; j = j + 1) {
+					yedi_muji (j == 2) { ruk_muji; }
+					count = count + 1;
+				}
+			}
+			count;`,
+			6,
+		},
+		{
+			`thoos_muji find = kaam_gar_muji(arr, x) {
+				thoos_muji found = -1;
+				ghuma_muji (thoos_muji i = 0; i < lambai_muji(arr); i = i + 1) {
+					yedi_muji (arr[i] == x) {
+						found = i;
+						ruk_muji;
+					}
+				}
+				patha_muji found;
+			};
+			find([4, 5, 6], 5);`,
+			1,
+		},
+		{
+			`thoos_muji cleanups = 0;
+			ghuma_muji (thoos_muji i = 0; i < 5; i = i + 1) {
+				kosis_gar_muji {
+					yedi_muji (i == 3) { ruk_muji; }
+					arko_muji;
+				} jasari_pani_muji {
+					cleanups = cleanups + 1;
+				}
+			}
+			cleanups;`,
+			4,
+		},
+		{
+			`thoos_muji f = kaam_gar_muji() {
+				jaba_samma_muji (sacho_muji) {
+					patha_muji 7;
+				}
+			};
+			f();`,
+			7,
+		},
+		{
+			// ruk_muji and arko_muji inside an expression leave it without finishing it
+			`thoos_muji r = [];
+			ghuma_muji (thoos_muji i = 1; i < 4; i = i + 1) {
+				khaad_muji(r, [i, yedi_muji (i == 2) { ruk_muji; }]);
+			}
+			lambai_muji(r);`,
+			1,
+		},
+		{
+			`thoos_muji sum = 0;
+			ghuma_muji (thoos_muji i = 0; i < 5; i = i + 1) {
+				sum = sum + [i, yedi_muji (i % 2 == 0) { arko_muji; }][0];
+			}
+			sum;`,
+			4,
+		},
+		{
+			`thoos_muji calls = 0;
+			thoos_muji f = kaam_gar_muji(a, b) { calls = calls + 1; };
+			thoos_muji i = 0;
+			jaba_samma_muji (i < 5) {
+				i = i + 1;
+				f(i, yedi_muji (i == 3) { ruk_muji; });
+			}
+			calls;`,
+			2,
+		},
+		{
+			`thoos_muji f = kaam_gar_muji() {
+				thoos_muji a = [1, yedi_muji (sacho_muji) { patha_muji 7; }];
+				patha_muji 0;
+			};
+			f();`,
+			7,
+		},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
 // hash maps
 func TestHashMapEval(t *testing.T) {
 	tests := []struct {
@@ -565,7 +1364,7 @@ func TestHashMapEval(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		if evaluated.Type() != object.INTEGER_OBJ {
 			t.Fatalf("expected integer; got=%T", evaluated)
 		}
@@ -576,6 +1375,282 @@ func TestHashMapEval(t *testing.T) {
 	}
 }
 
+func TestHashMapKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{1: "one", 2: "two"}[2]`, "two"},
+		{`{"1": "string", 1: "integer"}["1"]`, "string"},
+		{`{"1": "string", 1: "integer"}[1]`, "integer"},
+		{`{1.5: "a"}[1.5]`, "a"},
+		{`{0.0: "zero"}[-0.0]`, "zero"},
+		{`{sacho_muji: "yes", jhut_muji: "no"}[1 > 2]`, "no"},
+		{`{(1, "a"): "pair"}[(1, "a")]`, "pair"},
+		{`{(1, (2, 3)): "nested"}[(1, (2, 3))]`, "nested"},
+		{`{99999999999999999999: "big"}[99999999999999999990 + 9]`, "big"},
+		{`{"a": 1}["b"]`, "khali_muji"},
+		{`thoos_muji h = {"k": 0}; h[7] = "seven"; h[(7,)] = "tuple"; h[7] + h[(7,)]`, "seventuple"},
+		{`thoos_muji counts = {1: 0, 2: 0}; ghuma_muji (thoos_muji x : [1, 2, 1]) { counts[x] = counts[x] + 1; } counts[1] * 10 + counts[2]`, "21"},
+		{`{1: [1]}`, "{1: [1]}"},
+		{`{"a": "b"}`, `{"a": b}`},
+		{`{(1, "a"): 2}`, `{(1, "a"): 2}`},
+		{`{2.5: sacho_muji}`, "{2.500000: sacho_muji}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s. got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`{[1]: 2}`, "ARRAY cannot be a hashmap key"},
+		{`{"a": 1}[{"a": 1}]`, "HASHMAP cannot be a hashmap key"},
+		{`thoos_muji h = {"a": 1}; h[khaad_muji([], 1)] = 2`, "NULL cannot be a hashmap key"},
+		{`{(1, [2]): 3}`, "(1, [2]) cannot be a hashmap key"},
+		{`{0.0 / 0.0: 1}`, "NaN cannot be a hashmap key"},
+	}
+	for _, tt := range errors {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestHashMapOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"z": 1, "a": 2, 5: 3, "m": 4}`, `{"z": 1, "a": 2, 5: 3, "m": 4}`},
+		{`thoos_muji h = {"b": 1, "a": 2}; h["c"] = 3; h["b"] = 4; h`, `{"b": 4, "a": 2, "c": 3}`},
+		{`{"k": 1, "j": 2, "k": 3}`, `{"k": 3, "j": 2}`},
+		{`thoos_muji out = ""; ghuma_muji (thoos_muji k, v : {10: "a", 9: "b", "x": "c"}) { out = out + "${k}${v} "; } out`, "10a 9b xc "},
+		// keys added while walking are not visited
+		{`thoos_muji h = {1: 1, 2: 2}; ghuma_muji (thoos_muji k : h) { h[k + 10] = 0; } h`, "{1: 1, 2: 2, 11: 0, 12: 0}"},
+		{`thoos_muji h = {1: 1, 2: 2}; ghuma_muji (thoos_muji k, v : h) { h[2] = 5; h[k] = v; } h`, "{1: 1, 2: 5}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s. got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{3, 1, 3, "a", 1}`, `{3, 1, "a"}`},
+		{`{(1, 2), (1, 2)}`, "{(1, 2)}"},
+		{`samuha_muji()`, "samuha_muji()"},
+		{`samuha_muji([2, 1, 2, 1])`, "{2, 1}"},
+		{`samuha_muji("muji mu")`, `{"m", "u", "j", "i", " "}`},
+		{`samuha_muji({"b": 1, "a": 2})`, `{"b", "a"}`},
+		{`{}`, "{}"},
+		{`{1, 2, 3} | {3, 4}`, "{1, 2, 3, 4}"},
+		{`{1, 2, 3} & {3, 2, 5}`, "{2, 3}"},
+		{`{1, 2, 3} - {2}`, "{1, 3}"},
+		{`{1, 2, 3} ^ {3, 4}`, "{1, 2, 4}"},
+		{`{1, 2} & {3}`, "samuha_muji()"},
+		{`{1} | {2} & {1}`, "{1}"},
+		{`{1, 2} == {2, 1}`, "sacho_muji"},
+		{`{1, 2} == {1, 2, 3}`, "jhut_muji"},
+		{`{1, 2} != {1, 3}`, "sacho_muji"},
+		{`samuha_muji() == samuha_muji()`, "sacho_muji"},
+		{`chha_muji({1, 2}, 2)`, "sacho_muji"},
+		{`chha_muji({1, 2}, 2.0)`, "jhut_muji"},
+		{`chha_muji({1, 2}, [1])`, "jhut_muji"},
+		{`chha_muji({"a": 1}, "a")`, "sacho_muji"},
+		{`chha_muji([1, 2.5, sacho_muji], 2.5)`, "sacho_muji"},
+		{`chha_muji((1, 2), 3)`, "jhut_muji"},
+		{`lambai_muji({1, 1, 2})`, "2"},
+		{`thoos_muji s = {1}; khaad_muji(s, 2); khaad_muji(s, 1); s`, "{1, 2}"},
+		{`thoos_muji sum = 0; ghuma_muji (thoos_muji x : {1, 2, 1, 3}) { sum = sum + x; } sum`, "6"},
+		{`thoos_muji out = ""; ghuma_muji (thoos_muji i, x : {"a", "b"}) { out = out + "${i}${x}"; } out`, "0a1b"},
+		{`thoos_muji seen = samuha_muji(); thoos_muji dups = 0; ghuma_muji (thoos_muji x : [1, 2, 1, 3, 2]) { yedi_muji (chha_muji(seen, x)) { dups = dups + 1; } khaad_muji(seen, x); } dups`, "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s. got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`{1, [2]}`, "ARRAY cannot be a hashmap key"},
+		{`{1} | [1]`, "unsupported operation SET | ARRAY"},
+		{`1 & 2`, "unsupported operation INTEGER & INTEGER"},
+		{`{1} - 1`, "unsupported operation SET - INTEGER"},
+		{`samuha_muji(5)`, "cannot iterate over INTEGER"},
+		{`chha_muji(5, 5)`, "argument to `chha_muji` not supported, got INTEGER"},
+	}
+	for _, tt := range errors {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestDeepEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" + "b" == "ab"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "a"`, false},
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, 2] == [1, 2, 3]`, false},
+		{`[1, [2, "x"]] == [1, [2, "x"]]`, true},
+		{`[1] == [1.0]`, false},
+		{`[] == []`, true},
+		{`(1, [2]) == (1, [2])`, true},
+		{`(1, 2) == [1, 2]`, false},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{} == {}`, true},
+		{`{(1, "a")} == {(1, "a")}`, true},
+		{`thoos_muji f = kaam_gar_muji() { 1 }; f == f`, true},
+		{`kaam_gar_muji() { 1 } == kaam_gar_muji() { 1 }`, false},
+		{`thoos_muji x = 0.0 / 0.0; [x] == [x]`, false},
+		// cycles
+		{`thoos_muji a = [1]; khaad_muji(a, a); thoos_muji b = [1]; khaad_muji(b, b); a == b`, true},
+		{`thoos_muji a = [1]; khaad_muji(a, a); thoos_muji b = [2]; khaad_muji(b, b); a == b`, false},
+		{`thoos_muji a = [1]; khaad_muji(a, a); thoos_muji b = [1]; khaad_muji(b, a); a == b`, true},
+		{`thoos_muji h = {"k": 0}; h["self"] = h; thoos_muji g = {"k": 0}; g["self"] = g; h == g`, true},
+		{`thoos_muji h = {"k": 0}; h["self"] = h; thoos_muji g = {"k": 0}; g["self"] = g; g["k"] = 1; h == g`, false},
+		{`thoos_muji a = []; thoos_muji h = {"a": a}; khaad_muji(a, h); thoos_muji b = []; khaad_muji(b, {"a": b}); a == b`, true},
+		// identity
+		{`thoos_muji a = [1]; eutai_muji(a, a)`, true},
+		{`eutai_muji([1], [1])`, false},
+		{`thoos_muji a = [1]; thoos_muji b = a; khaad_muji(b, 2); eutai_muji(a, b)`, true},
+		{`eutai_muji({"a": 1}, {"a": 1})`, false},
+		{`eutai_muji(1, 1)`, true},
+		{`eutai_muji("muji", "mu" + "ji")`, true},
+		{`eutai_muji(1, 1.0)`, false},
+		{`eutai_muji(lambai_muji, lambai_muji)`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if !testBoolObject(t, evaluated, tt.expected) {
+			t.Errorf("wrong result for %q", tt.input)
+		}
+	}
+}
+
+func TestTuples(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(1, "a", [2])`, "(1, a, [2])"},
+		{`(1,)`, "(1,)"},
+		{`()`, "()"},
+		{`(1)`, "1"},
+		{`(1, 2,)`, "(1, 2)"},
+		{`(1, 2, 3)[-1]`, "3"},
+		{`(1, 2, 3)[1:]`, "(2, 3)"},
+		{`lambai_muji((1, 2, 3))`, "3"},
+		{`thoos_muji sum = 0; ghuma_muji (thoos_muji i, x : (5, 6)) { sum = sum + i * x; } sum`, "6"},
+		{`thoos_muji a = [1]; thoos_muji t = (a, 2); a[0] = 9; t`, "([9], 2)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s. got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+
+	evaluated := testEval(t, `thoos_muji t = (1, 2); t[0] = 3`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "tuples cannot be changed, build a new one instead" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`thoos_muji sum = 10; thoos_muji n = 4; "total: ${sum}, avg: ${sum / n}"`, "total: 10, avg: 2"},
+		{`"${1.5} ${sacho_muji} ${[1, "two"]}"`, "1.500000 sacho_muji [1, two]"},
+		{`thoos_muji name = "muji"; "${name}${name}"`, "mujimuji"},
+		{`thoos_muji f = kaam_gar_muji(x) { "<${x}>" }; "a ${f("b ${1 + 1}")} c"`, "a <b 2> c"},
+		{`thoos_muji h = {"k": 3}; "${ h["k"] * 2 }"`, "6"},
+		{`"\${sum} costs $5"`, "${sum} costs $5"},
+		{`thoos_muji out = ""; ghuma_muji (thoos_muji i = 0; i < 3; i = i + 1) { out = "${out}${i},"; } out`, "0,1,2,"},
+		{`thoos_muji a = []; khaad_muji(a, a); "${a}"`, "[[...]]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testStringObject(t, evaluated, tt.expected)
+	}
+
+	evaluated := testEval(t, `"before ${1 + sacho_muji} after"`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "unsupported operation INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+// nodes the tree walker cannot evaluate are reported on the runtime's stderr, not mixed into the program's output
+func TestUnknownNode(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	env := object.NewEnvironmentWithRuntime(object.NewRuntime(&stdout, &stderr, strings.NewReader("")))
+	node := &ast.NabhaeMujiExpression{
+		Token:      token.Token{Type: token.NABHAE_MUJI, Literal: "nabhae_muji"},
+		Condition:  &ast.Boolean{Token: token.Token{Type: token.SACHO_MUJI, Literal: "sacho_muji"}, Value: true},
+		Consequent: &ast.BlockStatement{},
+	}
+	if evaluated := eval.Eval(node, env); evaluated != nil {
+		t.Errorf("expected no value. got=%+v", evaluated)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("nothing should be written to stdout. got=%q", stdout.String())
+	}
+	if !strings.HasPrefix(stderr.String(), "FATAL: Eval() does not implement nabhae_muji (") {
+		t.Errorf("wrong message on stderr. got=%q", stderr.String())
+	}
+}
+
 // re-assignment
 
 func TestCustom(t *testing.T) {
@@ -595,6 +1670,6 @@ func TestCustom(t *testing.T) {
 	checkNumber(1);
 	`
 
-	_ = testEval(program)
+	_ = testEval(t, program)
 	fmt.Println("Passed!")
 }
@@ -0,0 +1,151 @@
+package eval
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/udeshyadhungana/interprerer/app/object"
+)
+
+// int64 arithmetic that reports false instead of wrapping around
+// the vm uses these for its shortcut, so they are shared
+
+func AddInt(l, r int64) (int64, bool) {
+	s := l + r
+	// overflowed when both operands have the sign the sum lacks
+	return s, (l^s)&(r^s) >= 0
+}
+
+func SubInt(l, r int64) (int64, bool) {
+	d := l - r
+	return d, (l^r)&(l^d) >= 0
+}
+
+func MulInt(l, r int64) (int64, bool) {
+	if l == 0 || r == 0 {
+		return 0, true
+	}
+	p := l * r
+	if (l == -1 && r == math.MinInt64) || (r == -1 && l == math.MinInt64) || p/r != l {
+		return p, false
+	}
+	return p, true
+}
+
+// Integer or BigInteger
+func isInteger(obj object.Object) bool {
+	t := obj.Type()
+	return t == object.INTEGER_OBJ || t == object.BIG_INTEGER_OBJ
+}
+
+func toBig(obj object.Object) *big.Int {
+	switch o := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(o.Value)
+	case *object.BigInteger:
+		return o.Value
+	default:
+		return nil
+	}
+}
+
+func toFloat(obj object.Object) float64 {
+	switch o := obj.(type) {
+	case *object.Integer:
+		return float64(o.Value)
+	case *object.BigInteger:
+		f, _ := new(big.Float).SetInt(o.Value).Float64()
+		return f
+	case *object.Float:
+		return o.Value
+	default:
+		return 0
+	}
+}
+
+// l op r for two integers, moving to a BigInteger when the result does not fit in an int64
+func evalIntegerArithmetic(rt *object.Runtime, left object.Object, operator string, right object.Object) object.Object {
+	if r, ok := right.(*object.Integer); ok && r.Value == 0 && (operator == "/" || operator == "%") {
+		return divisionByZero(operator)
+	}
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			var v int64
+			fits := true
+			switch operator {
+			case "+":
+				v, fits = AddInt(l.Value, r.Value)
+			case "-":
+				v, fits = SubInt(l.Value, r.Value)
+			case "*":
+				v, fits = MulInt(l.Value, r.Value)
+			case "/":
+				// the only quotient that overflows
+				fits = l.Value != math.MinInt64 || r.Value != -1
+				if fits {
+					v = l.Value / r.Value
+				}
+			case "%":
+				v = l.Value % r.Value
+			default:
+				return newError("unsupported operation %s %s %s", left.Type(), operator, right.Type())
+			}
+			if fits {
+				return &object.Integer{Value: v}
+			}
+		}
+	}
+
+	l, r := toBig(left), toBig(right)
+	res := new(big.Int)
+	switch operator {
+	case "+":
+		res.Add(l, r)
+	case "-":
+		res.Sub(l, r)
+	case "*":
+		res.Mul(l, r)
+	case "/":
+		res.Quo(l, r)
+	case "%":
+		res.Rem(l, r)
+	default:
+		return newError("unsupported operation %s %s %s", left.Type(), operator, right.Type())
+	}
+	return newInteger(rt, res)
+}
+
+func divisionByZero(operator string) *object.Error {
+	if operator == "%" {
+		return newErrorWithKind(object.ZERO_DIVISION_ERROR, "modulo by zero")
+	}
+	return newErrorWithKind(object.ZERO_DIVISION_ERROR, "division by zero")
+}
+
+// the integer holding v, a BigInteger is charged to rt
+func newInteger(rt *object.Runtime, v *big.Int) object.Object {
+	if v.IsInt64() {
+		return &object.Integer{Value: v.Int64()}
+	}
+	if err := rt.Allocate(object.BigIntegerSize(v.BitLen())); err != nil {
+		return err
+	}
+	return &object.BigInteger{Value: v}
+}
+
+// -1, 0 or 1 as l is less than, equal to or greater than r
+func compareIntegers(left object.Object, right object.Object) int {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch {
+			case l.Value < r.Value:
+				return -1
+			case l.Value > r.Value:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	return toBig(left).Cmp(toBig(right))
+}
@@ -0,0 +1,95 @@
+package eval
+
+import (
+	"github.com/udeshyadhungana/interprerer/app/ast"
+	"github.com/udeshyadhungana/interprerer/app/object"
+)
+
+func evalSetExpression(node *ast.SetExpression, env *object.Environment) object.Object {
+	result := object.NewSet(len(node.Elements))
+	for _, e := range node.Elements {
+		elem := Eval(e, env)
+		if isInterrupt(elem) {
+			return elem
+		}
+		if err := AddToSet(env.Runtime(), result, elem); err != nil {
+			return err
+		}
+	}
+	return result
+}
+
+// adds elem to s unless it is already there
+func AddToSet(rt *object.Runtime, s *object.Set, elem object.Object) *object.Error {
+	key, err := hashKey(elem)
+	if err != nil {
+		return err
+	}
+	if s.Has(key) {
+		return nil
+	}
+	// an element costs about as much as a hashmap entry
+	if err := rt.Allocate(object.PairSize(len(key.Value))); err != nil {
+		return err
+	}
+	s.Add(key, elem)
+	return nil
+}
+
+// whether elem is in s, elements that cannot be in a set never are
+func setHas(s *object.Set, elem object.Object) bool {
+	key, err := hashKey(elem)
+	return err == nil && s.Has(key)
+}
+
+// | union, & intersection, - difference and ^ symmetric difference
+// the result lists the elements of left first, in their order, then those of right
+func evalSetOperation(rt *object.Runtime, left object.Object, operator string, right object.Object) object.Object {
+	l, lok := left.(*object.Set)
+	r, rok := right.(*object.Set)
+	if !lok || !rok {
+		return newError("unsupported operation %s %s %s", left.Type(), operator, right.Type())
+	}
+	var elems []object.Object
+	// the elements of from that are in other, or are not when in is false
+	pick := func(from *object.Set, other *object.Set, in bool) {
+		for _, elem := range from.Elements() {
+			if setHas(other, elem) == in {
+				elems = append(elems, elem)
+			}
+		}
+	}
+	switch operator {
+	case "|":
+		elems = append(append(elems, l.Elements()...), r.Elements()...)
+	case "&":
+		pick(l, r, true)
+	case "-":
+		pick(l, r, false)
+	case "^":
+		pick(l, r, false)
+		pick(r, l, false)
+	default:
+		return newError("unsupported operation %s %s %s", left.Type(), operator, right.Type())
+	}
+	result := object.NewSet(len(elems))
+	for _, elem := range elems {
+		if err := AddToSet(rt, result, elem); err != nil {
+			return err
+		}
+	}
+	return result
+}
+
+// sets are equal when they have the same elements, whatever their order
+func setsEqual(l *object.Set, r *object.Set) bool {
+	if l.Len() != r.Len() {
+		return false
+	}
+	for _, elem := range l.Elements() {
+		if !setHas(r, elem) {
+			return false
+		}
+	}
+	return true
+}
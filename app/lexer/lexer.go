@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"fmt"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/udeshyadhungana/interprerer/app/token"
@@ -10,22 +11,55 @@ import (
 
 type Lexer struct {
 	input        string
-	position     int  // current position
-	readPosition int  // current position + 1
-	ch           rune // character under examination
-	inComment    bool
+	file         string // name reported in token positions, may be empty
+	position     int    // current position
+	readPosition int    // current position + 1
+	ch           rune   // character under examination
+	line         int    // line of ch
+	column       int    // column of ch
 	errors       []string
+
+	// strings whose interpolation is being lexed, innermost last
+	interpolations []interpolation
+}
+
+type interpolation struct {
+	start  token.Position // of the string's opening '"'
+	braces int            // '{' opened inside the interpolation and not closed yet
 }
 
 func NewLexer(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewLexerWithFile("", input)
+}
+
+// same as NewLexer, but every token remembers the file it came from
+func NewLexerWithFile(file string, input string) *Lexer {
+	l := &Lexer{input: input, file: file, line: 1}
 	l.readRune()
 	return l
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
 	l.skipWhiteSpace()
+	for l.ch == '$' {
+		if !l.skipComment() {
+			break
+		}
+		l.skipWhiteSpace()
+	}
+
+	start := l.currentPosition()
+	tok := l.readToken()
+	tok.Pos = start
+	tok.End = l.currentPosition()
+	if tok.Type == token.EOF {
+		tok.End = start
+	}
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	// symbols
@@ -49,7 +83,16 @@ func (l *Lexer) NextToken() token.Token {
 	case '%':
 		tok = token.NewToken(token.MOD, l.ch)
 	case '"':
-		tok.Literal = l.readString()
+		start := l.currentPosition()
+		var closed bool
+		tok.Literal, closed = l.readString(start)
+		tok.Type = token.STRING
+		if !closed {
+			tok.Type = token.STRING_HEAD
+			l.interpolations = append(l.interpolations, interpolation{start: start})
+		}
+	case '`':
+		tok.Literal = l.readRawString()
 		tok.Type = token.STRING
 	//array
 	case '[':
@@ -81,6 +124,22 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = token.NewToken(token.GT, l.ch)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			l.readRune()
+			tok = token.NewTokenFromStr(token.AND, "&&")
+		} else {
+			tok = token.NewToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			l.readRune()
+			tok = token.NewTokenFromStr(token.OR, "||")
+		} else {
+			tok = token.NewToken(token.PIPE, l.ch)
+		}
+	case '^':
+		tok = token.NewToken(token.CARET, l.ch)
 	case ':':
 		tok = token.NewToken(token.COLON, l.ch)
 	// delimiters
@@ -88,33 +147,38 @@ func (l *Lexer) NextToken() token.Token {
 		tok = token.NewToken(token.SEMICOLON, l.ch)
 	case ',':
 		tok = token.NewToken(token.COMMA, l.ch)
-	case '$':
-		if l.inComment {
-			l.inComment = false
-			l.readRune()
-			return l.NextToken()
-		} else {
-			l.inComment = true
-			l.readRune()
-			for l.ch != '$' && l.ch != 0 {
-				l.readRune()
-			}
-			if l.ch == 0 {
-				l.errors = append(l.errors, "unterminated comment")
-				return token.Token{Type: token.EOF, Literal: ""}
-			} else {
-				return l.NextToken()
-			}
-		}
 	case '(':
 		tok = token.NewToken(token.LPAREN, l.ch)
 	case ')':
 		tok = token.NewToken(token.RPAREN, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].braces++
+		}
 		tok = token.NewToken(token.LBRACE, l.ch)
 	case '}':
-		tok = token.NewToken(token.RBRACE, l.ch)
+		n := len(l.interpolations)
+		if n == 0 || l.interpolations[n-1].braces > 0 {
+			if n > 0 {
+				l.interpolations[n-1].braces--
+			}
+			tok = token.NewToken(token.RBRACE, l.ch)
+			break
+		}
+		// the '}' closing an interpolation, the string goes on after it
+		start := l.interpolations[n-1].start
+		var closed bool
+		tok.Literal, closed = l.readString(start)
+		tok.Type = token.STRING_MID
+		if closed {
+			tok.Type = token.STRING_TAIL
+			l.interpolations = l.interpolations[:n-1]
+		}
 	case 0:
+		if n := len(l.interpolations); n > 0 {
+			l.errorAt(l.interpolations[0].start, "unterminated string")
+			l.interpolations = nil
+		}
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
@@ -143,7 +207,21 @@ func (l *Lexer) ReportErrors() {
 	}
 }
 
+func (l *Lexer) errorAt(pos token.Position, format string, a ...any) {
+	l.errors = append(l.errors, fmt.Sprintf("%s: %s", pos, fmt.Sprintf(format, a...)))
+}
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{File: l.file, Line: l.line, Column: l.column, Offset: l.position}
+}
+
 func (l *Lexer) readRune() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
 	var r rune
 	var size int
 	if l.readPosition >= len(l.input) {
@@ -164,31 +242,262 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
+// reads an integer or float literal
+// integers can be written in hex (0xFF), binary (0b1010) or octal (0o755), floats can have
+// an exponent (6.02e23), and '_' can separate the digits of either (1_000_000)
 func (l *Lexer) readNumber() token.Token {
+	start := l.currentPosition()
 	position := l.position
-	for utils.IsDigit(l.ch) {
-		l.readRune()
+
+	if l.ch == '0' {
+		base, name := 0, ""
+		switch l.peekChar() {
+		case 'x', 'X':
+			base, name = 16, "hexadecimal"
+		case 'b', 'B':
+			base, name = 2, "binary"
+		case 'o', 'O':
+			base, name = 8, "octal"
+		}
+		if base != 0 {
+			l.readRune()
+			l.readRune()
+			if l.readDigits(base, name, true) == 0 {
+				l.errorAt(start, "%s literal has no digits", name)
+			}
+			return token.NewTokenFromStr(token.INT, l.input[position:l.position])
+		}
 	}
+
+	tokenType := token.TokenType(token.INT)
+	l.readDigits(10, "", false)
 	if l.ch == '.' {
+		tokenType = token.FLOAT
+		l.readRune()
+		l.readDigits(10, "", false)
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		tokenType = token.FLOAT
 		l.readRune()
-		for utils.IsDigit(l.ch) {
+		if l.ch == '+' || l.ch == '-' {
 			l.readRune()
 		}
-		return token.NewTokenFromStr(token.FLOAT, l.input[position:l.position])
-	} else {
-		return token.NewTokenFromStr(token.INT, l.input[position:l.position])
+		if l.readDigits(10, "", false) == 0 {
+			l.errorAt(l.currentPosition(), "exponent has no digits")
+		}
+	}
+	if l.ch == '.' {
+		// 1.2.3, the rest goes into the same token so it is not read as something else
+		l.errorAt(l.currentPosition(), "unexpected . in number literal")
+		for l.ch == '.' || utils.IsDigit(l.ch) || l.ch == '_' {
+			l.readRune()
+		}
+	}
+
+	literal := l.input[position:l.position]
+	// like go and c, an integer with a leading 0 is octal
+	if tokenType == token.INT && len(literal) > 1 && literal[0] == '0' {
+		for i, ch := range literal {
+			if ch == '8' || ch == '9' {
+				pos := start
+				pos.Column += i
+				pos.Offset += i
+				l.errorAt(pos, "invalid digit '%c' in octal literal", ch)
+				break
+			}
+		}
+	}
+	return token.NewTokenFromStr(tokenType, literal)
+}
+
+// reads digits and the '_' between them, returns how many digits were read
+// decimal digits that are too big for base are reported, name is the kind of literal
+// afterPrefix allows a '_' right after a base prefix, like 0x_FF
+func (l *Lexer) readDigits(base int, name string, afterPrefix bool) int {
+	isDigit := func(ch rune) bool {
+		d, ok := hexValue(ch)
+		return ok && (d < 10 || base == 16)
+	}
+	count := 0
+	reported := false
+	for {
+		if l.ch == '_' {
+			if (count == 0 && !afterPrefix) || !isDigit(rune(l.peekChar())) {
+				l.errorAt(l.currentPosition(), "'_' must separate successive digits")
+			}
+		} else if isDigit(l.ch) {
+			if d, _ := hexValue(l.ch); int(d) >= base && !reported {
+				l.errorAt(l.currentPosition(), "invalid digit '%c' in %s literal", l.ch, name)
+				reported = true
+			}
+			count++
+		} else {
+			return count
+		}
+		l.readRune()
+	}
+}
+
+// reads the text of a string after the current '"' or '}' and decodes its escapes
+// it stops at the closing '"', or returns closed=false at the '{' of an interpolation
+// start is the position of the string's opening '"'
+func (l *Lexer) readString(start token.Position) (text string, closed bool) {
+	var out strings.Builder
+	for {
+		l.readRune()
+		switch l.ch {
+		case '"':
+			return out.String(), true
+		case 0:
+			l.errorAt(start, "unterminated string")
+			return out.String(), true
+		case '$':
+			if l.peekChar() == '{' {
+				l.readRune()
+				return out.String(), false
+			}
+			out.WriteRune(l.ch)
+		case '\\':
+			if !l.readEscape(&out) {
+				l.errorAt(start, "unterminated string")
+				return out.String(), true
+			}
+		default:
+			out.WriteRune(l.ch)
+		}
 	}
 }
 
-func (l *Lexer) readString() string {
+// decodes the escape starting at the current '\\', leaves ch on its last character
+// returns false if the input ends instead
+func (l *Lexer) readEscape(out *strings.Builder) bool {
+	pos := l.currentPosition()
+	l.readRune()
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '0':
+		out.WriteByte(0)
+	case '\\', '"', '$':
+		out.WriteRune(l.ch)
+	case 'u':
+		l.readUnicodeEscape(pos, 4, out)
+	case 'U':
+		l.readUnicodeEscape(pos, 8, out)
+	case 0:
+		return false
+	default:
+		l.errorAt(pos, "invalid escape sequence \\%c", l.ch)
+		out.WriteRune(l.ch)
+	}
+	return true
+}
+
+// reads the digits of \uXXXX or \UXXXXXXXX
+func (l *Lexer) readUnicodeEscape(pos token.Position, digits int, out *strings.Builder) {
+	var r int64
+	for i := 0; i < digits; i++ {
+		d, ok := hexValue(rune(l.peekChar()))
+		if !ok {
+			l.errorAt(pos, "invalid unicode escape, expected %d hex digits", digits)
+			return
+		}
+		l.readRune()
+		r = r*16 + int64(d)
+	}
+	if r > utf8.MaxRune || !utf8.ValidRune(rune(r)) {
+		l.errorAt(pos, "invalid unicode escape, %X is not a code point", r)
+		return
+	}
+	out.WriteRune(rune(r))
+}
+
+func hexValue(ch rune) (rune, bool) {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return ch - '0', true
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10, true
+	case 'A' <= ch && ch <= 'F':
+		return ch - 'A' + 10, true
+	default:
+		return 0, false
+	}
+}
+
+// reads the raw string starting at the current '`', nothing in it is escaped
+// a raw string that starts on a new line loses that line break and its common indentation
+func (l *Lexer) readRawString() string {
+	start := l.currentPosition()
 	position := l.position + 1
 	for {
 		l.readRune()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == '`' || l.ch == 0 {
 			break
 		}
 	}
-	return l.input[position:l.position]
+	if l.ch == 0 {
+		l.errorAt(start, "unterminated raw string")
+	}
+	// like go, carriage returns are dropped so the value does not depend on the line endings of the file
+	text := strings.ReplaceAll(l.input[position:l.position], "\r", "")
+	if !strings.HasPrefix(text, "\n") {
+		return text
+	}
+	return dedent(text[1:])
+}
+
+// removes the indentation every non-blank line starts with
+// a last line holding only the indentation of the closing '`' is dropped
+func dedent(text string) string {
+	lines := strings.Split(text, "\n")
+	if last := lines[len(lines)-1]; strings.TrimLeft(last, " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	indent := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimLeft(line, " \t") == "" {
+			continue
+		}
+		lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent, first = lead, false
+			continue
+		}
+		for !strings.HasPrefix(lead, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	for i, line := range lines {
+		if strings.HasPrefix(line, indent) {
+			lines[i] = line[len(indent):]
+		} else {
+			// a blank line shorter than the indentation
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// skips a comment starting at the current '$'
+// returns false if the comment never ends
+func (l *Lexer) skipComment() bool {
+	start := l.currentPosition()
+	l.readRune()
+	for l.ch != '$' && l.ch != 0 {
+		l.readRune()
+	}
+	if l.ch == 0 {
+		l.errorAt(start, "unterminated comment")
+		return false
+	}
+	l.readRune()
+	return true
 }
 
 func (l *Lexer) skipWhiteSpace() {
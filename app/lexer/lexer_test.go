@@ -43,6 +43,9 @@ func TestNextToken(t *testing.T) {
 	{"foo": "bar"}
 	69.69
 	$sacho_muji$
+	a && b || c;
+	ruk_muji; arko_muji;
+	a | b & c ^ d;
 	`
 
 	tests := []struct {
@@ -174,6 +177,24 @@ func TestNextToken(t *testing.T) {
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
 		{token.FLOAT, "69.69"},
+		{token.IDFIER, "a"},
+		{token.AND, "&&"},
+		{token.IDFIER, "b"},
+		{token.OR, "||"},
+		{token.IDFIER, "c"},
+		{token.SEMICOLON, ";"},
+		{token.RUK_MUJI, "ruk_muji"},
+		{token.SEMICOLON, ";"},
+		{token.ARKO_MUJI, "arko_muji"},
+		{token.SEMICOLON, ";"},
+		{token.IDFIER, "a"},
+		{token.PIPE, "|"},
+		{token.IDFIER, "b"},
+		{token.AMPERSAND, "&"},
+		{token.IDFIER, "c"},
+		{token.CARET, "^"},
+		{token.IDFIER, "d"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 	l := NewLexer(input)
@@ -188,3 +209,264 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "thoos_muji 界 = 5;\n$ comment $ \"foo\"\n  x"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedPos    string
+		expectedEnd    string
+		expectedOffset int
+	}{
+		{token.THOOS_MUJI, "f.muji:1:1", "f.muji:1:11", 0},
+		{token.IDFIER, "f.muji:1:12", "f.muji:1:13", 11},
+		{token.ASSIGN, "f.muji:1:14", "f.muji:1:15", 15},
+		{token.INT, "f.muji:1:16", "f.muji:1:17", 17},
+		{token.SEMICOLON, "f.muji:1:17", "f.muji:1:18", 18},
+		{token.STRING, "f.muji:2:13", "f.muji:2:18", 32},
+		{token.IDFIER, "f.muji:3:3", "f.muji:3:4", 40},
+		{token.EOF, "f.muji:3:4", "f.muji:3:4", 41},
+	}
+
+	l := NewLexerWithFile("f.muji", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] failed - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos.String() != tt.expectedPos {
+			t.Fatalf("tests[%d] failed - position wrong. expected=%s, got=%s", i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End.String() != tt.expectedEnd {
+			t.Fatalf("tests[%d] failed - end position wrong. expected=%s, got=%s", i, tt.expectedEnd, tok.End)
+		}
+		if tok.Pos.Offset != tt.expectedOffset {
+			t.Fatalf("tests[%d] failed - offset wrong. expected=%d, got=%d", i, tt.expectedOffset, tok.Pos.Offset)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"foo bar"`, "foo bar"},
+		{`"line\nnext"`, "line\nnext"},
+		{`"a\tb\rc"`, "a\tb\rc"},
+		{`"say \"muji\""`, `say "muji"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"nul\0"`, "nul\x00"},
+		{`"\u0928\u092E\u0938\u094D\u0924\u0947"`, "नमस्ते"},
+		{`"\U0001F600"`, "😀"},
+		{`"नमस्ते"`, "नमस्ते"},
+		{`""`, ""},
+	}
+
+	for i, tt := range tests {
+		l := NewLexer(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING {
+			t.Fatalf("tests[%d] failed - tokentype wrong. expected=%q, got=%q", i, token.STRING, tok.Type)
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("tests[%d] failed - literal wrong. expected=%q, got=%q", i, tt.expected, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("tests[%d] failed - unexpected errors: %v", i, l.Errors())
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("tests[%d] failed - expected EOF after the string. got=%q", i, next.Type)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"total: ${sum}, ${ {"k": n}["k"] } \${x} $" + "a${"b${c}"}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_HEAD, "total: "},
+		{token.IDFIER, "sum"},
+		{token.STRING_MID, ", "},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.IDFIER, "n"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.STRING_TAIL, " ${x} $"},
+		{token.PLUS, "+"},
+		{token.STRING_HEAD, "a"},
+		{token.STRING_HEAD, "b"},
+		{token.IDFIER, "c"},
+		{token.STRING_TAIL, ""},
+		{token.STRING_TAIL, ""},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] failed - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] failed - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected errors: %v", l.Errors())
+	}
+}
+
+func TestRawStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"`plain`", "plain"},
+		{"`no \\n ${escapes} \"here\"`", `no \n ${escapes} "here"`},
+		{"`two\nlines`", "two\nlines"},
+		{"`\n\tSELECT *\n\tFROM users\n\t\tWHERE id = 1\n\t`", "SELECT *\nFROM users\n\tWHERE id = 1"},
+		{"`\n    {\n\n      \"a\": 1\n    }\n  `", "{\n\n  \"a\": 1\n}"},
+		{"`\n  keep\n  trailing\n\n`", "keep\ntrailing\n"},
+		{"`\r\n  crlf\r\n  lines\r\n  `", "crlf\nlines"},
+		{"``", ""},
+	}
+
+	for i, tt := range tests {
+		l := NewLexer(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING {
+			t.Fatalf("tests[%d] failed - tokentype wrong. expected=%q, got=%q", i, token.STRING, tok.Type)
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("tests[%d] failed - literal wrong. expected=%q, got=%q", i, tt.expected, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("tests[%d] failed - unexpected errors: %v", i, l.Errors())
+		}
+	}
+
+	l := NewLexer("`a\nb\nc` x")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Pos.String() != "3:4" {
+		t.Errorf("wrong position after a raw string. got=%s", tok.Pos)
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType token.TokenType
+	}{
+		{"1_000_000", token.INT},
+		{"0xFF", token.INT},
+		{"0Xdead_BEEF", token.INT},
+		{"0x_ff", token.INT},
+		{"0b1010", token.INT},
+		{"0B1_0", token.INT},
+		{"0o755", token.INT},
+		{"0755", token.INT},
+		{"0", token.INT},
+		{"3.14", token.FLOAT},
+		{"1.", token.FLOAT},
+		{"1e-9", token.FLOAT},
+		{"6.02E23", token.FLOAT},
+		{"1e+5", token.FLOAT},
+		{"1_000.000_5e1_0", token.FLOAT},
+		{"09.5", token.FLOAT},
+	}
+
+	for i, tt := range tests {
+		l := NewLexer(tt.input + ";")
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Errorf("tests[%d] failed - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.input {
+			t.Errorf("tests[%d] failed - literal wrong. expected=%q, got=%q", i, tt.input, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("tests[%d] failed - unexpected errors: %v", i, l.Errors())
+		}
+		if next := l.NextToken(); next.Type != token.SEMICOLON {
+			t.Errorf("tests[%d] failed - expected ; after the number. got=%q", i, next.Type)
+		}
+	}
+}
+
+func TestNumberErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1.2.3", []string{"1:4: unexpected . in number literal"}},
+		{"x = 0x;", []string{"1:5: hexadecimal literal has no digits"}},
+		{"0b", []string{"1:1: binary literal has no digits"}},
+		{"0b1021", []string{"1:5: invalid digit '2' in binary literal"}},
+		{"0o78", []string{"1:4: invalid digit '8' in octal literal"}},
+		{"0789", []string{"1:3: invalid digit '8' in octal literal"}},
+		{"1e", []string{"1:3: exponent has no digits"}},
+		{"2.5e+;", []string{"1:6: exponent has no digits"}},
+		{"1__000", []string{"1:2: '_' must separate successive digits"}},
+		{"1000_", []string{"1:5: '_' must separate successive digits"}},
+		{"1_.5", []string{"1:2: '_' must separate successive digits"}},
+		{"1._5", []string{"1:3: '_' must separate successive digits"}},
+	}
+
+	for i, tt := range tests {
+		l := NewLexer(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+		errs := l.Errors()
+		if len(errs) != len(tt.expected) {
+			t.Errorf("tests[%d] failed - expected %d errors. got=%q", i, len(tt.expected), errs)
+			continue
+		}
+		for j, msg := range tt.expected {
+			if errs[j] != msg {
+				t.Errorf("tests[%d] failed - error %d wrong. expected=%q, got=%q", i, j, msg, errs[j])
+			}
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`"never ends`, []string{"1:1: unterminated string"}},
+		{`x = "never ends\`, []string{"1:5: unterminated string"}},
+		{`"bad \q escape"`, []string{`1:6: invalid escape sequence \q`}},
+		{`"open ${x + "inner ${y`, []string{"1:1: unterminated string"}},
+		{"x = `raw\nnever ends", []string{"1:5: unterminated raw string"}},
+		{`"\u12"`, []string{"1:2: invalid unicode escape, expected 4 hex digits"}},
+		{`"\UFFFFFFFF"`, []string{"1:2: invalid unicode escape, FFFFFFFF is not a code point"}},
+		{`"\uD800"`, []string{"1:2: invalid unicode escape, D800 is not a code point"}},
+		{"\"\\x\n\\y", []string{`1:2: invalid escape sequence \x`, `2:1: invalid escape sequence \y`, "1:1: unterminated string"}},
+	}
+
+	for i, tt := range tests {
+		l := NewLexer(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+		errs := l.Errors()
+		if len(errs) != len(tt.expected) {
+			t.Errorf("tests[%d] failed - expected %d errors. got=%q", i, len(tt.expected), errs)
+			continue
+		}
+		for j, msg := range tt.expected {
+			if errs[j] != msg {
+				t.Errorf("tests[%d] failed - error %d wrong. expected=%q, got=%q", i, j, msg, errs[j])
+			}
+		}
+	}
+}
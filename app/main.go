@@ -1,23 +1,34 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/user"
 
+	"github.com/udeshyadhungana/interprerer/app/compiler"
 	"github.com/udeshyadhungana/interprerer/app/eval"
 	"github.com/udeshyadhungana/interprerer/app/lexer"
 	"github.com/udeshyadhungana/interprerer/app/object"
 	"github.com/udeshyadhungana/interprerer/app/parser"
 	"github.com/udeshyadhungana/interprerer/app/repl"
+	"github.com/udeshyadhungana/interprerer/app/vm"
 )
 
+// eval walks the syntax tree, vm compiles the program to bytecode and runs that
+var engine = flag.String("engine", "eval", "engine that runs the program: eval or vm")
+
 func main() {
-	if len(os.Args) == 1 {
+	flag.Parse()
+	if *engine != "eval" && *engine != "vm" {
+		fmt.Fprintf(os.Stderr, "unknown engine %q, use eval or vm\n", *engine)
+		os.Exit(2)
+	}
+	if flag.NArg() == 0 {
 		startRepl()
 	} else {
-		filePath := os.Args[1]
+		filePath := flag.Arg(0)
 		interpret(filePath)
 	}
 }
@@ -29,7 +40,11 @@ func startRepl() {
 	}
 	fmt.Printf("नमस्कार %s मुजी!\n", user.Username)
 	fmt.Println("यो \"मुजी\" भाषा हो। तल लेख् मुजी 👇")
-	repl.Start(os.Stdin, os.Stdout)
+	if *engine == "vm" {
+		repl.StartVM(os.Stdin, os.Stdout)
+	} else {
+		repl.Start(os.Stdin, os.Stdout)
+	}
 }
 
 func interpret(filepath string) {
@@ -37,7 +52,7 @@ func interpret(filepath string) {
 	if err != nil {
 		panic(err)
 	}
-	l := lexer.NewLexer(string(fileContents))
+	l := lexer.NewLexerWithFile(filepath, string(fileContents))
 	p := parser.NewParser(l)
 	program := p.ParseProgram()
 	hasErrs := p.CheckAndReportErrors()
@@ -45,9 +60,23 @@ func interpret(filepath string) {
 		return
 	}
 
-	env := object.NewEnvironment()
-	evaluated := eval.Eval(program, env)
-	if evaluated != nil && evaluated.Type() != object.NULL_OBJ {
+	var evaluated object.Object
+	if *engine == "vm" {
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			io.WriteString(os.Stdout, err.Error())
+			io.WriteString(os.Stdout, "\n")
+			return
+		}
+		evaluated = vm.New(c.Bytecode()).Run()
+	} else {
+		env := object.NewEnvironment()
+		evaluated = eval.Eval(program, env)
+	}
+	if err, ok := evaluated.(*object.Error); ok {
+		io.WriteString(os.Stdout, err.Traceback())
+		io.WriteString(os.Stdout, "\n")
+	} else if evaluated != nil && evaluated.Type() != object.NULL_OBJ {
 		io.WriteString(os.Stdout, evaluated.Inspect())
 		io.WriteString(os.Stdout, "\n")
 	}
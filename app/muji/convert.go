@@ -0,0 +1,421 @@
+package muji
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+
+	"github.com/udeshyadhungana/interprerer/app/object"
+)
+
+var (
+	objectType  = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	runtimeType = reflect.TypeOf((*object.Runtime)(nil))
+	bigIntType  = reflect.TypeOf((*big.Int)(nil))
+	anyType     = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+// ToObject converts a go value into the muji value scripts see
+// integers, *big.Int, floats, strings, bools, slices, maps and nil are supported,
+// the keys of a map must convert to values that can be hashmap keys, and end up sorted
+func ToObject(v any) (object.Object, error) {
+	if v == nil {
+		return object.NULL, nil
+	}
+	if obj, ok := v.(object.Object); ok {
+		return obj, nil
+	}
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	if v.Kind() != reflect.Interface && v.Type().Implements(objectType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return object.NULL, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		// the script must not see later changes the host makes to it
+		return object.NewInteger(new(big.Int).Set(v.Interface().(*big.Int))), nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Bool:
+		if v.Bool() {
+			return object.TRUE, nil
+		}
+		return object.FALSE, nil
+	case reflect.Slice:
+		arr := &object.Array{Arr: make([]object.Object, v.Len())}
+		for i := range arr.Arr {
+			elem, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			arr.Arr[i] = elem
+		}
+		return arr, nil
+	case reflect.Map:
+		hash := object.NewHashMap(v.Len())
+		for _, k := range sortedKeys(v) {
+			key, err := toObject(k)
+			if err != nil {
+				return nil, err
+			}
+			h, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert %s, %s cannot be a hashmap key", v.Type(), key.Type())
+			}
+			hk, ok := h.HashKey()
+			if !ok {
+				return nil, fmt.Errorf("cannot convert %s, %s cannot be a hashmap key", v.Type(), key.Inspect())
+			}
+			val, err := toObject(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(hk, object.HashPair{Key: key, Value: val})
+		}
+		return hash, nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		return ToObject(v.Interface())
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a muji value", v.Type())
+	}
+}
+
+// converts a muji value into a go value of type t
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if t == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+	if t.Kind() != reflect.Interface && t.Implements(objectType) {
+		if reflect.TypeOf(obj) != t {
+			return reflect.Value{}, fmt.Errorf("expected %s, got %s", t, obj.Type())
+		}
+		return reflect.ValueOf(obj), nil
+	}
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("expected %s, got %s", t, obj.Type())
+	}
+	if t == bigIntType {
+		switch n := obj.(type) {
+		case *object.Integer:
+			return reflect.ValueOf(big.NewInt(n.Value)), nil
+		case *object.BigInteger:
+			return reflect.ValueOf(new(big.Int).Set(n.Value)), nil
+		default:
+			return mismatch()
+		}
+	}
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if b, ok := obj.(*object.BigInteger); ok {
+			return reflect.Value{}, fmt.Errorf("%s does not fit in %s", b.Value, t)
+		}
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		if v.OverflowInt(i.Value) {
+			return reflect.Value{}, fmt.Errorf("%d does not fit in %s", i.Value, t)
+		}
+		v.SetInt(i.Value)
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Float:
+			v.SetFloat(n.Value)
+		case *object.Integer:
+			v.SetFloat(float64(n.Value))
+		case *object.BigInteger:
+			f, _ := new(big.Float).SetInt(n.Value).Float64()
+			v.SetFloat(f)
+		default:
+			return mismatch()
+		}
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return mismatch()
+		}
+		v.SetString(s.Value)
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch()
+		}
+		v.SetBool(b.Value)
+	case reflect.Slice:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+		v.Set(reflect.MakeSlice(t, len(arr.Arr), len(arr.Arr)))
+		for i, e := range arr.Arr {
+			elem, err := fromObject(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Index(i).Set(elem)
+		}
+	case reflect.Map:
+		hash, ok := obj.(*object.HashMap)
+		if !ok {
+			return mismatch()
+		}
+		v.Set(reflect.MakeMapWithSize(t, hash.Len()))
+		for _, pair := range hash.Pairs() {
+			key, err := mapKey(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			elem, err := fromObject(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Interface:
+		// any gets the natural go value
+		val, err := toGo(obj, map[object.Object]bool{})
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if val != nil {
+			v.Set(reflect.ValueOf(val))
+		}
+	default:
+		return mismatch()
+	}
+	return v, nil
+}
+
+// int64, *big.Int, float64, string, bool, []any, map[string]any or nil
+// a tuple or set becomes []any, a hashmap with keys that are not all strings map[any]any
+// values without a go counterpart, like functions, stay object.Object
+// inside holds the arrays and hashmaps being converted, one that contains itself cannot be converted
+func toGo(obj object.Object, inside map[object.Object]bool) (any, error) {
+	switch o := obj.(type) {
+	case *object.Integer:
+		return o.Value, nil
+	case *object.BigInteger:
+		return new(big.Int).Set(o.Value), nil
+	case *object.Float:
+		return o.Value, nil
+	case *object.String:
+		return o.Value, nil
+	case *object.Boolean:
+		return o.Value, nil
+	case *object.Null:
+		return nil, nil
+	case *object.Array:
+		if inside[o] {
+			return nil, fmt.Errorf("cannot convert an array that contains itself")
+		}
+		inside[o] = true
+		defer delete(inside, o)
+		return toGoSlice(o.Arr, inside)
+	case *object.Tuple:
+		return toGoSlice(o.Elements, inside)
+	case *object.Set:
+		return toGoSlice(o.Elements(), inside)
+	case *object.HashMap:
+		if inside[o] {
+			return nil, fmt.Errorf("cannot convert a hashmap that contains itself")
+		}
+		inside[o] = true
+		defer delete(inside, o)
+		allStrings := true
+		for _, pair := range o.Pairs() {
+			_, ok := pair.Key.(*object.String)
+			allStrings = allStrings && ok
+		}
+		if allStrings {
+			hash := make(map[string]any, o.Len())
+			for _, pair := range o.Pairs() {
+				val, err := toGo(pair.Value, inside)
+				if err != nil {
+					return nil, err
+				}
+				hash[pair.Key.(*object.String).Value] = val
+			}
+			return hash, nil
+		}
+		hash := make(map[any]any, o.Len())
+		for _, pair := range o.Pairs() {
+			key, _ := mapKey(pair.Key, anyType)
+			val, err := toGo(pair.Value, inside)
+			if err != nil {
+				return nil, err
+			}
+			hash[key.Interface()] = val
+		}
+		return hash, nil
+	default:
+		return obj, nil
+	}
+}
+
+func toGoSlice(elems []object.Object, inside map[object.Object]bool) ([]any, error) {
+	arr := make([]any, len(elems))
+	for i, e := range elems {
+		val, err := toGo(e, inside)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = val
+	}
+	return arr, nil
+}
+
+// go maps have no order, the keys of a converted one are sorted so that it prints the same every time
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Bool:
+			return !a.Bool() && b.Bool()
+		default:
+			return fmt.Sprint(a) < fmt.Sprint(b)
+		}
+	})
+	return keys
+}
+
+// key as a go map key of type t
+// []any cannot be a map key, so a tuple under an any key stays an object.Object
+func mapKey(key object.Object, t reflect.Type) (reflect.Value, error) {
+	if _, ok := key.(*object.Tuple); ok && t.Kind() == reflect.Interface {
+		return reflect.ValueOf(key), nil
+	}
+	return fromObject(key, t)
+}
+
+// whether values of t can cross between go and muji
+func convertible(t reflect.Type) bool {
+	if t.Implements(objectType) || t == bigIntType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool:
+		return true
+	case reflect.Slice:
+		return convertible(t.Elem())
+	case reflect.Map:
+		return convertible(t.Key()) && convertible(t.Elem())
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	default:
+		return false
+	}
+}
+
+// wraps a go function into a builtin, see Interpreter.Register
+func wrap(name string, fn any) (*object.Builtin, error) {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("cannot register %s: expected a function, got %s", name, t)
+	}
+
+	// a leading *object.Runtime parameter is not an argument, it gets the runtime of the call
+	first := 0
+	if t.NumIn() > 0 && t.In(0) == runtimeType {
+		first = 1
+	}
+	for i := first; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			in = in.Elem()
+		}
+		if !convertible(in) {
+			return nil, fmt.Errorf("cannot register %s: unsupported parameter type %s", name, t.In(i))
+		}
+	}
+	switch {
+	case t.NumOut() > 2:
+		return nil, fmt.Errorf("cannot register %s: too many results", name)
+	case t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("cannot register %s: the second result must be an error", name)
+	case t.NumOut() > 0 && t.Out(0) != errorType && !convertible(t.Out(0)):
+		return nil, fmt.Errorf("cannot register %s: unsupported result type %s", name, t.Out(0))
+	}
+
+	return &object.Builtin{
+		Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			params := t.NumIn() - first
+			if (!t.IsVariadic() && len(args) != params) || (t.IsVariadic() && len(args) < params-1) {
+				return newError("wrong number of arguments to `%s`. expected %d, got %d", name, params, len(args))
+			}
+			in := make([]reflect.Value, 0, first+len(args))
+			if first == 1 {
+				in = append(in, reflect.ValueOf(rt))
+			}
+			for i, arg := range args {
+				var pt reflect.Type
+				if t.IsVariadic() && first+i >= t.NumIn()-1 {
+					pt = t.In(t.NumIn() - 1).Elem()
+				} else {
+					pt = t.In(first + i)
+				}
+				val, err := fromObject(arg, pt)
+				if err != nil {
+					return newError("argument %d to `%s`: %s", i+1, name, err)
+				}
+				in = append(in, val)
+			}
+			return fromResults(rt, name, v.Call(in))
+		},
+	}, nil
+}
+
+// a non-nil error becomes a muji runtime error, no results become khali_muji
+// a converted result is new to the program, and is charged to its memory limit
+func fromResults(rt *object.Runtime, name string, out []reflect.Value) object.Object {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return newError("%s", err)
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return object.NULL
+	}
+	obj, err := toObject(out[0])
+	if err != nil {
+		return newError("result of `%s`: %s", name, err)
+	}
+	if !out[0].Type().Implements(objectType) {
+		if err := rt.Allocate(object.Size(obj)); err != nil {
+			return err
+		}
+	}
+	return obj
+}
+
+func newError(format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.RUNTIME_ERROR}
+}
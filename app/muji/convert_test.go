@@ -0,0 +1,213 @@
+package muji
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/udeshyadhungana/interprerer/app/object"
+)
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       any
+		input    string
+		expected string
+	}{
+		{"add", func(a, b int64) int64 { return a + b }, `add(2, 3);`, "5"},
+		{"half", func(x float64) float64 { return x / 2 }, `half(3);`, "1.500000"},
+		{"shout", func(s string) string { return strings.ToUpper(s) + "!" }, `shout("muji");`, "MUJI!"},
+		{"negate", func(b bool) bool { return !b }, `negate(jhut_muji);`, "sacho_muji"},
+		{"sum", func(xs []int64) int64 {
+			total := int64(0)
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		}, `sum([1, 2, 3]);`, "6"},
+		{"count", func(m map[string]int64) int { return len(m) }, `count({"a": 1, "b": 2});`, "2"},
+		{"squares", func(n int) map[int]int {
+			m := map[int]int{}
+			for i := 1; i <= n; i++ {
+				m[i] = i * i
+			}
+			return m
+		}, `squares(3)[3];`, "9"},
+		{"keyType", func(m map[any]string) string {
+			for k := range m {
+				return fmt.Sprintf("%T", k)
+			}
+			return ""
+		}, `keyType({(1, 2): "a"});`, "*object.Tuple"},
+		{"words", func(s string) []string { return strings.Fields(s) }, `words("ek dui tin")[2];`, "tin"},
+		{"describe", func(v any) string { return fmt.Sprintf("%T", v) }, `describe([1, "a"]);`, "[]interface {}"},
+		{"join", func(sep string, parts ...string) string { return strings.Join(parts, sep) }, `join("-", "a", "b", "c");`, "a-b-c"},
+		{"kind", func(o object.Object) string { return string(o.Type()) }, `kind(kaam_gar_muji() {});`, "KAAM_GAR"},
+		{"nothing", func() {}, `nothing();`, "khali_muji"},
+		{"check", func(x int64) error {
+			if x < 0 {
+				return errors.New("negative")
+			}
+			return nil
+		}, `check(1);`, "khali_muji"},
+		{"div", func(a, b int64) (int64, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		}, `kosis_gar_muji { div(1, 0); } samat_muji (e) { e["kind"] + ": " + e["message"]; }`, "RuntimeError: division by zero"},
+		{"square", func(x *big.Int) *big.Int { return x.Mul(x, x) }, `square(99999999999999999999);`, "9999999999999999999800000000000000000001"},
+		{"shrink", func(x *big.Int) *big.Int { return x.Rsh(x, 70) }, `shrink(4 * 0x200000000000000000);`, "2"},
+		{"echo", func(rt *object.Runtime, s string) string {
+			fmt.Fprint(rt.Stderr, s)
+			return s
+		}, `echo("x");`, "x"},
+	}
+
+	for _, engine := range engines {
+		for _, tt := range tests {
+			in := New(Options{Engine: engine, Stderr: &strings.Builder{}})
+			if err := in.Register(tt.name, tt.fn); err != nil {
+				t.Fatalf("%s: cannot register %s: %s", engine, tt.name, err)
+			}
+			result, err := in.Run(context.Background(), tt.input)
+			if err != nil {
+				t.Errorf("%s: %s failed: %s", engine, tt.input, err)
+				continue
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %s wrong. expected=%q, got=%q", engine, tt.input, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       any
+		input    string
+		expected string
+	}{
+		{"add", func(a, b int64) int64 { return a + b }, `add(1);`, "wrong number of arguments to `add`. expected 2, got 1"},
+		{"add", func(a, b int64) int64 { return a + b }, `add(1, "2");`, "argument 2 to `add`: expected int64, got STRING"},
+		{"small", func(a int8) int8 { return a }, `small(300);`, "argument 1 to `small`: 300 does not fit in int8"},
+		{"small", func(a int64) int64 { return a }, `small(99999999999999999999);`, "argument 1 to `small`: 99999999999999999999 does not fit in int64"},
+		{"fail", func() (string, error) { return "", errors.New("boom") }, `fail();`, "boom"},
+	}
+
+	for _, engine := range engines {
+		for _, tt := range tests {
+			in := New(Options{Engine: engine})
+			if err := in.Register(tt.name, tt.fn); err != nil {
+				t.Fatalf("%s: cannot register %s: %s", engine, tt.name, err)
+			}
+			_, err := in.Run(context.Background(), tt.input)
+			var errObj *object.Error
+			if !errors.As(err, &errObj) {
+				t.Errorf("%s: %s expected a runtime error. got=%v", engine, tt.input, err)
+				continue
+			}
+			if errObj.Message != tt.expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", engine, tt.expected, errObj.Message)
+			}
+		}
+	}
+
+	in := New(Options{})
+	invalid := map[string]any{
+		"notFunc":    5,
+		"channel":    func(c chan int) {},
+		"results":    func() (int, int) { return 0, 0 },
+		"chanKeys":   func(m map[chan int]string) {},
+		"thoos_muji": func() {},
+		"bad name":   func() {},
+	}
+	for name, fn := range invalid {
+		if err := in.Register(name, fn); err == nil {
+			t.Errorf("registering %s should fail", name)
+		}
+	}
+}
+
+func TestRegisterIsPerInterpreter(t *testing.T) {
+	for _, engine := range engines {
+		ctx := context.Background()
+		in := New(Options{Engine: engine})
+		if err := in.Register("secret", func() int64 { return 42 }); err != nil {
+			t.Fatal(err)
+		}
+		if result, err := in.Run(ctx, `secret();`); err != nil || result.Inspect() != "42" {
+			t.Errorf("%s: secret() wrong. got=%v, %v", engine, result, err)
+		}
+		if _, err := New(Options{Engine: engine}).Run(ctx, `secret();`); err == nil {
+			t.Errorf("%s: secret leaked into another interpreter", engine)
+		}
+	}
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, "khali_muji"},
+		{7, "7"},
+		{2.5, "2.500000"},
+		{"s", "s"},
+		{[]any{1, "a", true}, "[1, a, sacho_muji]"},
+		{map[string][]int{"x": {1}}, `{"x": [1]}`},
+		{map[int]string{7: "x", -1: "y", 10: "z"}, "{-1: y, 7: x, 10: z}"},
+		{map[any]int{2.5: 1}, "{2.500000: 1}"},
+		{new(big.Int).Lsh(big.NewInt(1), 64), "18446744073709551616"},
+	}
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Fatalf("cannot convert %v: %s", tt.input, err)
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("wrong conversion of %v. expected=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+	if _, err := ToObject(struct{}{}); err == nil {
+		t.Errorf("converting a struct should fail")
+	}
+
+	v, err := fromObject(&object.Array{Arr: []object.Object{&object.Integer{Value: 1}, object.NULL}}, reflect.TypeOf([]any{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v.Interface(), []any{int64(1), nil}) {
+		t.Errorf("wrong conversion to go. got=%#v", v.Interface())
+	}
+
+	hash := object.NewHashMap(1)
+	one := &object.Integer{Value: 1}
+	key, _ := one.HashKey()
+	hash.Set(key, object.HashPair{Key: one, Value: &object.String{Value: "a"}})
+	if got, err := toGo(hash, map[object.Object]bool{}); err != nil || !reflect.DeepEqual(got, map[any]any{int64(1): "a"}) {
+		t.Errorf("wrong conversion to go. got=%#v (%v)", got, err)
+	}
+
+	// an array held twice is fine, one inside itself has no go counterpart
+	inner := &object.Array{Arr: []object.Object{one}}
+	v, err = fromObject(&object.Array{Arr: []object.Object{inner, inner}}, anyType)
+	if err != nil || !reflect.DeepEqual(v.Interface(), []any{[]any{int64(1)}, []any{int64(1)}}) {
+		t.Errorf("wrong conversion to go. got=%#v (%v)", v, err)
+	}
+	inner.Arr = append(inner.Arr, &object.Tuple{Elements: []object.Object{inner}})
+	if _, err := fromObject(inner, anyType); err == nil || err.Error() != "cannot convert an array that contains itself" {
+		t.Errorf("converting an array inside itself should fail. got=%v", err)
+	}
+	cyclic := object.NewHashMap(1)
+	cyclic.Set(key, object.HashPair{Key: one, Value: cyclic})
+	if _, err := fromObject(cyclic, reflect.TypeOf(map[int]any{})); err == nil {
+		t.Errorf("converting a hashmap inside itself should fail")
+	}
+}
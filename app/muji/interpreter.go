@@ -0,0 +1,226 @@
+// Package muji runs muji programs from go
+//
+//	in := muji.New(muji.Options{Stdout: &out})
+//	_, err := in.Run(ctx, `thoos_muji double = kaam_gar_muji(x) { x * 2; };`)
+//	result, err := in.Call("double", &object.Integer{Value: 21})
+package muji
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/udeshyadhungana/interprerer/app/compiler"
+	"github.com/udeshyadhungana/interprerer/app/eval"
+	"github.com/udeshyadhungana/interprerer/app/lexer"
+	"github.com/udeshyadhungana/interprerer/app/object"
+	"github.com/udeshyadhungana/interprerer/app/parser"
+	"github.com/udeshyadhungana/interprerer/app/token"
+	"github.com/udeshyadhungana/interprerer/app/utils"
+	"github.com/udeshyadhungana/interprerer/app/vm"
+)
+
+// Engine decides how programs are run
+type Engine string
+
+const (
+	EngineEval Engine = "eval" // walks the syntax tree
+	EngineVM   Engine = "vm"   // compiles to bytecode and runs it on the vm
+)
+
+// Options configure an Interpreter, the zero value runs on the tree walker with the process' stdio
+type Options struct {
+	Engine Engine
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// float division and modulo by zero raise a ZeroDivisionError instead of giving Inf or NaN
+	StrictFloats bool
+
+	// a program that goes past a limit stops with an error of kind LimitError
+	MaxSteps  int64         // see object.Limits, unbounded when zero
+	MaxDepth  int           // nested calls, object.DefaultMaxDepth when zero
+	MaxMemory int64         // bytes of strings, arrays, hashmaps and sets a run may hold at once, unbounded when zero
+	Timeout   time.Duration // for every Run and Call, unbounded when zero
+}
+
+// Interpreter keeps the globals of the programs it runs
+// a function defined by one Run can be used by the next one, or called from go with Call
+// an Interpreter must not be used by more than one goroutine at a time
+type Interpreter struct {
+	engine  Engine
+	runtime *object.Runtime
+	timeout time.Duration
+
+	// tree walker
+	env *object.Environment
+
+	// vm
+	symbols   *compiler.SymbolTable
+	constants []object.Object
+	globals   []object.Object
+}
+
+func New(opts Options) *Interpreter {
+	if opts.Engine == "" {
+		opts.Engine = EngineEval
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+	if opts.Stdin == nil {
+		opts.Stdin = os.Stdin
+	}
+	rt := object.NewRuntime(opts.Stdout, opts.Stderr, opts.Stdin)
+	rt.Limits = object.Limits{MaxSteps: opts.MaxSteps, MaxDepth: opts.MaxDepth, MaxMemory: opts.MaxMemory}
+	rt.StrictFloats = opts.StrictFloats
+	return &Interpreter{
+		engine:  opts.Engine,
+		runtime: rt,
+		timeout: opts.Timeout,
+		env:     object.NewEnvironmentWithRuntime(rt),
+		symbols: compiler.NewSymbolTable(),
+	}
+}
+
+// ParseError lists everything wrong with the syntax of a program
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// runs source and returns the value of its last statement
+// a program that fails returns a *ParseError, or the *object.Error that stopped it
+// a program stopped by ctx returns an *object.Error that unwraps to ctx's error
+func (in *Interpreter) Run(ctx context.Context, source string) (object.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := in.withTimeout(ctx)
+	defer cancel()
+	l := lexer.NewLexer(source)
+	p := parser.NewParser(l)
+	program := p.ParseProgram()
+	if errs := append(l.Errors(), p.Errors()...); len(errs) != 0 {
+		return nil, &ParseError{Errors: errs}
+	}
+
+	var result object.Object
+	switch in.engine {
+	case EngineEval:
+		result = eval.EvalWithContext(ctx, program, in.env)
+	case EngineVM:
+		c := compiler.NewWithState(in.symbols, in.constants)
+		if err := c.Compile(program); err != nil {
+			return nil, err
+		}
+		bytecode := c.Bytecode()
+		in.constants = bytecode.Constants
+		machine := vm.NewWithRuntime(bytecode, in.globals, in.runtime)
+		result = machine.RunWithContext(ctx)
+		in.globals = machine.Globals()
+	default:
+		return nil, fmt.Errorf("unknown engine %q", in.engine)
+	}
+	return unwrap(result)
+}
+
+// calls the function or builtin bound to name
+func (in *Interpreter) Call(name string, args ...object.Object) (object.Object, error) {
+	fn, ok := in.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("identifier not found: %s", name)
+	}
+	ctx, cancel := in.withTimeout(context.Background())
+	defer cancel()
+	if in.engine == EngineVM {
+		bytecode := &compiler.Bytecode{Constants: in.constants, Globals: in.symbols.Names()}
+		machine := vm.NewWithRuntime(bytecode, in.globals, in.runtime)
+		result := machine.CallWithContext(ctx, fn, args...)
+		// the vm grows the globals to the symbol table, which may copy them, keep what the call wrote
+		in.globals = machine.Globals()
+		return unwrap(result)
+	}
+	in.runtime.Start(ctx)
+	return unwrap(eval.Call(fn, args, in.env))
+}
+
+func (in *Interpreter) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if in.timeout > 0 {
+		return context.WithTimeout(ctx, in.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Register makes the go function fn available to the scripts of this interpreter under name
+// arguments and results are converted between go and muji values: integers, floats, strings,
+// bools, slices, maps and object.Object are supported, a non-nil error result
+// is raised in the script as a runtime error
+// a first parameter of type *object.Runtime receives the interpreter's runtime instead of an argument
+//
+//	in.Register("greet", func(name string) (string, error) { ... })
+//
+// the function is bound like a global, so a script can shadow it with thoos_muji
+func (in *Interpreter) Register(name string, fn any) error {
+	if !isIdentifier(name) {
+		return fmt.Errorf("cannot register %q: not a valid identifier", name)
+	}
+	builtin, err := wrap(name, fn)
+	if err != nil {
+		return err
+	}
+	in.env.Set(name, builtin)
+	index := in.symbols.Define(name)
+	for len(in.globals) <= index {
+		in.globals = append(in.globals, nil)
+	}
+	in.globals[index] = builtin
+	return nil
+}
+
+func isIdentifier(name string) bool {
+	if name == "" || token.LookupIdentifier(name) != token.IDFIER {
+		return false
+	}
+	for _, ch := range name {
+		if !utils.IsLetter(ch) {
+			return false
+		}
+	}
+	return true
+}
+
+// the value of a global, or a builtin when no global has the name
+func (in *Interpreter) lookup(name string) (object.Object, bool) {
+	if in.engine == EngineVM {
+		if index, ok := in.symbols.Resolve(name); ok && index < len(in.globals) && in.globals[index] != nil {
+			return in.globals[index], true
+		}
+	} else if val, ok := in.env.Get(name); ok {
+		return val, true
+	}
+	if builtin, ok := eval.LookupBuiltin(name); ok {
+		return builtin, true
+	}
+	return nil, false
+}
+
+func unwrap(result object.Object) (object.Object, error) {
+	if err, ok := result.(*object.Error); ok {
+		return nil, err
+	}
+	if result == nil {
+		return object.NULL, nil
+	}
+	return result, nil
+}
@@ -0,0 +1,360 @@
+package muji
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/udeshyadhungana/interprerer/app/object"
+)
+
+var engines = []Engine{EngineEval, EngineVM}
+
+func TestRunWritesToStdout(t *testing.T) {
+	for _, engine := range engines {
+		var out bytes.Buffer
+		in := New(Options{Engine: engine, Stdout: &out})
+		_, err := in.Run(context.Background(), `bhan_muji("namaste ", 5); bhan_muji([1, 2]);`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", engine, err)
+		}
+		if out.String() != "namaste 5\n[1, 2]\n" {
+			t.Errorf("%s: wrong output. got=%q", engine, out.String())
+		}
+	}
+}
+
+func TestCyclicValues(t *testing.T) {
+	for _, engine := range engines {
+		var out bytes.Buffer
+		in := New(Options{Engine: engine, Stdout: &out})
+		if err := in.Register("host", func(v any) any { return v }); err != nil {
+			t.Fatal(err)
+		}
+		_, err := in.Run(context.Background(), `
+			thoos_muji a = [1]; khaad_muji(a, a); bhan_muji(a);
+			thoos_muji h = {"k": 1}; h["self"] = h; h["list"] = [h, (a,)]; bhan_muji(h);`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", engine, err)
+		}
+		expected := "[1, [...]]\n{\"k\": 1, \"self\": {...}, \"list\": [{...}, ([1, [...]],)]}\n"
+		if out.String() != expected {
+			t.Errorf("%s: wrong output. got=%q", engine, out.String())
+		}
+
+		_, err = in.Run(context.Background(), `host(a);`)
+		if err == nil || !strings.Contains(err.Error(), "cannot convert an array that contains itself") {
+			t.Errorf("%s: expected a conversion error. got=%v", engine, err)
+		}
+	}
+}
+
+func TestRunReadsFromStdin(t *testing.T) {
+	for _, engine := range engines {
+		in := New(Options{Engine: engine, Stdin: strings.NewReader("Udeshya\nDhungana")})
+		result, err := in.Run(context.Background(), `sun_muji() + " " + sun_muji();`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", engine, err)
+		}
+		if result.Inspect() != "Udeshya Dhungana" {
+			t.Errorf("%s: wrong result. got=%q", engine, result.Inspect())
+		}
+		result, err = in.Run(context.Background(), `sun_muji();`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", engine, err)
+		}
+		if result != object.NULL {
+			t.Errorf("%s: expected khali_muji at the end of stdin. got=%s", engine, result.Inspect())
+		}
+	}
+}
+
+func TestGlobalsOutliveRun(t *testing.T) {
+	for _, engine := range engines {
+		in := New(Options{Engine: engine})
+		ctx := context.Background()
+		programs := []string{
+			`thoos_muji total = 0;`,
+			`thoos_muji add = kaam_gar_muji(n) { total = total + n; patha_muji total; };`,
+			`add(5); add(10);`,
+		}
+		for _, p := range programs {
+			if _, err := in.Run(ctx, p); err != nil {
+				t.Fatalf("%s: unexpected error: %s", engine, err)
+			}
+		}
+		result, err := in.Call("add", &object.Integer{Value: 1})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", engine, err)
+		}
+		if result.Inspect() != "16" {
+			t.Errorf("%s: wrong result. got=%s", engine, result.Inspect())
+		}
+
+		// another interpreter has globals of its own
+		other := New(Options{Engine: engine})
+		if _, err := other.Run(ctx, `total;`); err == nil {
+			t.Errorf("%s: globals leaked into another interpreter", engine)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	for _, engine := range engines {
+		in := New(Options{Engine: engine})
+		_, err := in.Run(context.Background(), `
+			thoos_muji makeAdder = kaam_gar_muji(x) { kaam_gar_muji(y) { x + y; }; };
+			thoos_muji addTwo = makeAdder(2);
+			thoos_muji fail = kaam_gar_muji() { fyak_muji "nope"; };`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", engine, err)
+		}
+
+		result, err := in.Call("addTwo", &object.Integer{Value: 40})
+		if err != nil || result.Inspect() != "42" {
+			t.Errorf("%s: addTwo(40) wrong. got=%v, %v", engine, result, err)
+		}
+		result, err = in.Call("lambai_muji", &object.String{Value: "four"})
+		if err != nil || result.Inspect() != "4" {
+			t.Errorf("%s: lambai_muji wrong. got=%v, %v", engine, result, err)
+		}
+
+		_, err = in.Call("fail")
+		var errObj *object.Error
+		if !errors.As(err, &errObj) || errObj.Kind != object.THROWN_ERROR {
+			t.Errorf("%s: expected a thrown error. got=%v", engine, err)
+		}
+		if _, err := in.Call("addTwo"); err == nil {
+			t.Errorf("%s: expected an error for a missing argument", engine)
+		}
+		if _, err := in.Call("missing"); err == nil {
+			t.Errorf("%s: expected an error for an unknown function", engine)
+		}
+	}
+}
+
+func TestCallWritesGlobals(t *testing.T) {
+	for _, engine := range engines {
+		in := New(Options{Engine: engine})
+		ctx := context.Background()
+		_, err := in.Run(ctx, `thoos_muji count = 0; thoos_muji bump = kaam_gar_muji() { count = count + 1; };`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", engine, err)
+		}
+		// a program that does not compile still declares its globals, so there are more than the last run left
+		var failing strings.Builder
+		for i := 0; i < 50; i++ {
+			fmt.Fprintf(&failing, "thoos_muji unused%c%c = %d; ", 'a'+i/26, 'a'+i%26, i)
+		}
+		failing.WriteString("1 = 2;")
+		if _, err := in.Run(ctx, failing.String()); err == nil {
+			t.Fatalf("%s: expected assigning to 1 to fail", engine)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := in.Call("bump"); err != nil {
+				t.Fatalf("%s: unexpected error: %s", engine, err)
+			}
+		}
+		result, err := in.Run(ctx, `count;`)
+		if err != nil || result.Inspect() != "2" {
+			t.Errorf("%s: the writes of Call were lost. got=%v, %v", engine, result, err)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, engine := range engines {
+		in := New(Options{Engine: engine})
+		_, err := in.Run(context.Background(), `thoos_muji = 5; thoos_muji y 3;`)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || len(parseErr.Errors) != 2 {
+			t.Errorf("%s: expected two parse errors. got=%v", engine, err)
+		}
+
+		_, err = in.Run(context.Background(), `1 + sacho_muji;`)
+		var errObj *object.Error
+		if !errors.As(err, &errObj) || errObj.Message != "unsupported operation INTEGER + BOOLEAN" {
+			t.Errorf("%s: expected a runtime error. got=%v", engine, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := in.Run(ctx, `1;`); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected the context's error. got=%v", engine, err)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		opts     Options
+		input    string
+		expected string
+	}{
+		{Options{MaxSteps: 1000}, `jaba_samma_muji (sacho_muji) {}`, "step limit of 1000 exceeded"},
+		{Options{MaxSteps: 1000}, `thoos_muji f = kaam_gar_muji() { f(); }; f();`, "step limit of 1000 exceeded"},
+		{Options{MaxDepth: 50}, `thoos_muji f = kaam_gar_muji(n) { f(n + 1); }; f(0);`, "call depth limit of 50 exceeded"},
+		{Options{Timeout: 10 * time.Millisecond}, `jaba_samma_muji (sacho_muji) {}`, "execution stopped: context deadline exceeded"},
+		{
+			// the budget is spent, so the handler cannot keep the loop going
+			Options{MaxSteps: 1000},
+			`jaba_samma_muji (sacho_muji) { kosis_gar_muji { 1; } samat_muji (e) { 2; } }`,
+			"step limit of 1000 exceeded",
+		},
+	}
+
+	for _, engine := range engines {
+		for _, tt := range tests {
+			tt.opts.Engine = engine
+			in := New(tt.opts)
+			_, err := in.Run(context.Background(), tt.input)
+			var errObj *object.Error
+			if !errors.As(err, &errObj) {
+				t.Errorf("%s: %s expected a limit error. got=%v", engine, tt.input, err)
+				continue
+			}
+			if errObj.Kind != object.LIMIT_ERROR || errObj.Message != tt.expected {
+				t.Errorf("%s: wrong error. expected=%q, got=%s %q", engine, tt.expected, errObj.Kind, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestLimitsAreCatchable(t *testing.T) {
+	for _, engine := range engines {
+		in := New(Options{Engine: engine, MaxDepth: 20})
+		result, err := in.Run(context.Background(), `
+			thoos_muji f = kaam_gar_muji(n) { f(n + 1); };
+			kosis_gar_muji { f(0); } samat_muji (e) { e["kind"]; }`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", engine, err)
+		}
+		if result.Inspect() != object.LIMIT_ERROR {
+			t.Errorf("%s: wrong kind. got=%s", engine, result.Inspect())
+		}
+
+		// the limits start over with every run
+		in = New(Options{Engine: engine, MaxSteps: 500})
+		for i := 0; i < 3; i++ {
+			if _, err := in.Run(context.Background(), `ghuma_muji (thoos_muji i = 0; i < 10; i = i + 1) {}`); err != nil {
+				t.Errorf("%s: run %d ran out of steps: %s", engine, i, err)
+			}
+		}
+
+		// samat_muji cannot keep a program that ran out of steps going, it fails again where it starts
+		in = New(Options{Engine: engine, MaxSteps: 1000})
+		_, err = in.Run(context.Background(), `kosis_gar_muji { jaba_samma_muji(sacho_muji){} } samat_muji (e) { e["kind"]; }`)
+		var errObj *object.Error
+		if !errors.As(err, &errObj) || errObj.Kind != object.LIMIT_ERROR || errObj.Pos.String() != "1:65" {
+			t.Errorf("%s: expected a step limit error at 1:65. got=%v", engine, err)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	for _, engine := range engines {
+		in := New(Options{Engine: engine})
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+		_, err := in.Run(ctx, `thoos_muji i = 0; jaba_samma_muji (sacho_muji) { i = i + 1; }`)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected the run to be cancelled. got=%v", engine, err)
+		}
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	tests := []string{
+		`thoos_muji s = "muji"; jaba_samma_muji (sacho_muji) { s = s + s; }`,
+		`thoos_muji a = []; jaba_samma_muji (sacho_muji) { khaad_muji(a, 1); }`,
+		`thoos_muji a = [1]; jaba_samma_muji (sacho_muji) { a = a + a; }`,
+		`thoos_muji h = {"k": 0}; thoos_muji k = "k"; jaba_samma_muji (sacho_muji) { k = k + "k"; h[k] = 1; }`,
+		`thoos_muji keep = []; jaba_samma_muji (sacho_muji) { khaad_muji(keep, [1, 2, 3, 4, 5, 6, 7, 8]); }`,
+		`thoos_muji keep = []; jaba_samma_muji (sacho_muji) { khaad_muji(keep, {"a": 1, "b": 2}); }`,
+	}
+
+	for _, engine := range engines {
+		for _, input := range tests {
+			in := New(Options{Engine: engine, MaxMemory: 1 << 16})
+			_, err := in.Run(context.Background(), input)
+			var errObj *object.Error
+			if !errors.As(err, &errObj) {
+				t.Errorf("%s: %s expected a memory error. got=%v", engine, input, err)
+				continue
+			}
+			if errObj.Kind != object.LIMIT_ERROR || !strings.HasPrefix(errObj.Message, "memory limit exceeded") {
+				t.Errorf("%s: wrong error. got=%s %q", engine, errObj.Kind, errObj.Message)
+			}
+		}
+
+		// garbage does not count, only what the program still holds
+		bounded := []string{
+			`thoos_muji total = 0; ghuma_muji (thoos_muji i = 0; i < 100000; i = i + 1) { thoos_muji pair = [i, i + 1]; total = total + pair[1]; } total`,
+			`thoos_muji s = ""; ghuma_muji (thoos_muji i = 0; i < 100000; i = i + 1) { s = "muji ${i}"; } lambai_muji(s)`,
+			`thoos_muji f = kaam_gar_muji(n) { thoos_muji h = {"n": n, "s": {n, n + 1}}; h["n"] }; thoos_muji sum = 0; ghuma_muji (thoos_muji i = 0; i < 50000; i = i + 1) { sum = sum + f(i); } sum`,
+		}
+		for _, input := range bounded {
+			in := New(Options{Engine: engine, MaxMemory: 1 << 16})
+			if _, err := in.Run(context.Background(), input); err != nil {
+				t.Errorf("%s: %s should fit. got=%v", engine, input, err)
+			}
+		}
+
+		// values from go count as much as the ones the program makes
+		in := New(Options{Engine: engine, MaxMemory: 1 << 16})
+		if err := in.Register("big", func() string { return strings.Repeat("x", 1<<17) }); err != nil {
+			t.Fatal(err)
+		}
+		if err := in.Register("many", func(n int) []int { return make([]int, n) }); err != nil {
+			t.Fatal(err)
+		}
+		for _, input := range []string{`thoos_muji s = big();`, `thoos_muji a = many(10000);`} {
+			_, err := in.Run(context.Background(), input)
+			var errObj *object.Error
+			if !errors.As(err, &errObj) || errObj.Kind != object.LIMIT_ERROR {
+				t.Errorf("%s: %s expected a memory error. got=%v", engine, input, err)
+			}
+		}
+		if _, err := in.Run(context.Background(), `lambai_muji(many(100));`); err != nil {
+			t.Errorf("%s: a small result should fit. got=%v", engine, err)
+		}
+
+		// small programs fit, and every run gets the whole budget
+		in = New(Options{Engine: engine, MaxMemory: 1 << 12})
+		for i := 0; i < 3; i++ {
+			result, err := in.Run(context.Background(), `thoos_muji a = [1, 2, 3]; khaad_muji(a, "four"); lambai_muji(a);`)
+			if err != nil || result.Inspect() != "4" {
+				t.Errorf("%s: run %d wrong. got=%v, %v", engine, i, result, err)
+			}
+		}
+	}
+}
+
+func TestStrictFloats(t *testing.T) {
+	for _, engine := range engines {
+		strict := New(Options{Engine: engine, StrictFloats: true})
+		for _, input := range []string{"1.5 / 0", "1 / 0.0", "2.5 % 0.0"} {
+			_, err := strict.Run(context.Background(), input)
+			var errObj *object.Error
+			if !errors.As(err, &errObj) || errObj.Kind != object.ZERO_DIVISION_ERROR {
+				t.Errorf("%s: %s expected a ZeroDivisionError. got=%v", engine, input, err)
+			}
+		}
+		result, err := strict.Run(context.Background(), "1.5 / 0.5")
+		if err != nil || result.Inspect() != "3.000000" {
+			t.Errorf("%s: wrong result. got=%v, %v", engine, result, err)
+		}
+
+		result, err = New(Options{Engine: engine}).Run(context.Background(), "1.5 / 0")
+		if err != nil || result.Inspect() != "+Inf" {
+			t.Errorf("%s: expected +Inf without StrictFloats. got=%v, %v", engine, result, err)
+		}
+	}
+}
@@ -1,13 +1,23 @@
 package object
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	outer   *Environment
+	runtime *Runtime
 }
 
 func NewEnvironment() *Environment {
+	return NewEnvironmentWithRuntime(DefaultRuntime())
+}
+
+// a top level environment whose programs talk to rt instead of the process' stdout and stdin
+func NewEnvironmentWithRuntime(rt *Runtime) *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, runtime: rt}
+}
+
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -37,11 +47,16 @@ func (e *Environment) Set(name string, val Object) Object {
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
+	env := NewEnvironmentWithRuntime(outer.runtime)
 	env.outer = outer
 	return env
 }
 
-func (e *Environment) PopStack() *Environment {
-	return e.outer
+// the values of this environment and the ones around it
+func (e *Environment) EachValue(visit func(Object)) {
+	for ; e != nil; e = e.outer {
+		for _, v := range e.store {
+			visit(v)
+		}
+	}
 }
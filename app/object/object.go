@@ -3,15 +3,20 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 
 	"github.com/udeshyadhungana/interprerer/app/ast"
+	"github.com/udeshyadhungana/interprerer/app/token"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ       ObjectType = "INTEGER"
+	BIG_INTEGER_OBJ   ObjectType = "BIG_INTEGER"
 	FLOAT_OBJ         ObjectType = "FLOAT"
 	BOOLEAN_OBJ       ObjectType = "BOOLEAN"
 	NULL_OBJ          ObjectType = "NULL"
@@ -22,12 +27,29 @@ const (
 	BUILTIN_OBJECT    ObjectType = "BUILTIN"
 	ARRAY_OBJECT      ObjectType = "ARRAY"
 	HASHMAP_OBJECT    ObjectType = "HASHMAP"
+	TUPLE_OBJ         ObjectType = "TUPLE"
+	SET_OBJ           ObjectType = "SET"
+	EXCEPTION_OBJ     ObjectType = "EXCEPTION"
+	RUK_MUJI_OBJ      ObjectType = "BREAK"
+	ARKO_MUJI_OBJ     ObjectType = "CONTINUE"
+)
+
+// kinds of errors, scripts see them in the `kind` field of a caught error
+const (
+	RUNTIME_ERROR       = "RuntimeError"
+	INDEX_ERROR         = "IndexError"
+	ZERO_DIVISION_ERROR = "ZeroDivisionError" // integer division or modulo by zero, floats too when strict
+	THROWN_ERROR        = "ThrownError"       // raised by fyak_muji
+	LIMIT_ERROR         = "LimitError"        // the program ran out of steps or call depth, or was cancelled
 )
 
 var (
 	TRUE  = &Boolean{Value: true}
 	FALSE = &Boolean{Value: false}
 	NULL  = &Null{}
+
+	BREAK    = &Break{}
+	CONTINUE = &Continue{}
 )
 
 // common for all the data types
@@ -49,6 +71,29 @@ func (i *Integer) Type() ObjectType {
 	return INTEGER_OBJ
 }
 
+// an integer that does not fit in an int64, arithmetic on Integers moves to it when it overflows
+// a BigInteger never holds a value an Integer could, see NewInteger
+// its Value is never modified, operations make a new one
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (i *BigInteger) Inspect() string {
+	return i.Value.String()
+}
+
+func (i *BigInteger) Type() ObjectType {
+	return BIG_INTEGER_OBJ
+}
+
+// an Integer when v fits in an int64, a BigInteger otherwise
+func NewInteger(v *big.Int) Object {
+	if v.IsInt64() {
+		return &Integer{Value: v.Int64()}
+	}
+	return &BigInteger{Value: v}
+}
+
 // Float
 type Float struct {
 	Value float64
@@ -90,16 +135,138 @@ type Return struct {
 func (r *Return) Type() ObjectType { return PATHA_MUJI_OBJ }
 func (r *Return) Inspect() string  { return r.Value.Inspect() }
 
+// Break and Continue unwind the statements of a loop body up to the loop
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return RUK_MUJI_OBJ }
+func (b *Break) Inspect() string  { return "ruk_muji" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return ARKO_MUJI_OBJ }
+func (c *Continue) Inspect() string  { return "arko_muji" }
+
 // Error handling
 type Error struct {
 	Message string
+	Kind    string
+	Value   Object         // the value passed to fyak_muji, nil for runtime errors
+	Pos     token.Position // where the error was raised, zero if unknown
+	Stack   []Frame        // calls the error unwound through, innermost first
+	Cause   error          // the go error behind it, like the context's error for a cancelled program
+}
+
+// Frame is a call to a muji function that was active when an error was raised
+type Frame struct {
+	Function string
+	Pos      token.Position // the call site
+	Args     string         // short summary of the arguments
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("%s: %s(%s)", f.Pos, f.Function, f.Args)
+}
+
+func NewFrame(function string, pos token.Position, args []Object) Frame {
+	summary := make([]string, len(args))
+	for i, a := range args {
+		summary[i] = summarize(a)
+	}
+	return Frame{Function: function, Pos: pos, Args: strings.Join(summary, ", ")}
+}
+
+const maxSummaryLength = 20
+
+func summarize(o Object) string {
+	var s string
+	if str, ok := o.(*String); ok {
+		s = strconv.Quote(str.Value)
+	} else {
+		s = o.Inspect()
+	}
+	s = strings.ReplaceAll(s, "\n", " ")
+	if r := []rune(s); len(r) > maxSummaryLength {
+		return string(r[:maxSummaryLength]) + "..."
+	}
+	return s
 }
 
 func (e *Error) Type() ObjectType { return GALAT_MUJI_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+
+// an error that stopped a program is handed to go code as an error
+func (e *Error) Error() string { return e.Inspect() }
+func (e *Error) Unwrap() error { return e.Cause }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("ERROR: %s: %s", e.Pos, e.Message)
+	}
+	return "ERROR: " + e.Message
+}
+
+// Traceback lists the calls leading to the error, outermost first, followed by the error itself
+func (e *Error) Traceback() string {
+	if len(e.Stack) == 0 {
+		return e.Inspect()
+	}
+	var out bytes.Buffer
+	out.WriteString("Traceback (most recent call last):\n")
+	// deep recursion repeats the same call with different arguments, so collapse consecutive
+	// frames of the same function called from the same place
+	repeated := 0
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		if i < len(e.Stack)-1 && e.Stack[i].Function == e.Stack[i+1].Function && e.Stack[i].Pos == e.Stack[i+1].Pos {
+			repeated++
+			continue
+		}
+		if repeated > 0 {
+			out.WriteString(fmt.Sprintf("  [previous line repeated %d more times]\n", repeated))
+			repeated = 0
+		}
+		out.WriteString("  " + e.Stack[i].String() + "\n")
+	}
+	if repeated > 0 {
+		out.WriteString(fmt.Sprintf("  [previous line repeated %d more times]\n", repeated))
+	}
+	out.WriteString(e.Inspect())
+	return out.String()
+}
+
+// Exception is an error caught by samat_muji
+// unlike Error, it is an ordinary value that does not abort the program
+type Exception struct {
+	Err *Error
+}
+
+func (e *Exception) Type() ObjectType { return EXCEPTION_OBJ }
+func (e *Exception) Inspect() string  { return e.Err.Kind + ": " + e.Err.Message }
+
+// Field returns the fields scripts can read by indexing the exception
+func (e *Exception) Field(name string) (Object, bool) {
+	switch name {
+	case "message":
+		return &String{Value: e.Err.Message}, true
+	case "kind":
+		return &String{Value: e.Err.Kind}, true
+	case "value":
+		if e.Err.Value == nil {
+			return NULL, true
+		}
+		return e.Err.Value, true
+	case "stack":
+		// most recent call last, like the traceback
+		stack := &Array{}
+		for i := len(e.Err.Stack) - 1; i >= 0; i-- {
+			stack.Arr = append(stack.Arr, &String{Value: e.Err.Stack[i].String()})
+		}
+		return stack, true
+	default:
+		return nil, false
+	}
+}
 
 // Kaam gar
 type KaamGar struct {
+	Name       string // name it was first bound to, empty for anonymous functions
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -135,7 +302,7 @@ func (s *String) Type() ObjectType {
 }
 
 // builtin
-type BuiltinFunction func(args ...Object) Object
+type BuiltinFunction func(rt *Runtime, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
@@ -150,38 +317,238 @@ type Array struct {
 }
 
 func (a *Array) Inspect() string {
-	var result bytes.Buffer
-	result.WriteString("[")
-	elems := []string{}
-	for _, e := range a.Arr {
-		elems = append(elems, e.Inspect())
-	}
-	result.WriteString(strings.Join(elems, ", "))
-	result.WriteString("]")
-	return result.String()
+	return inspector{}.value(a)
 }
 
 func (a *Array) Type() ObjectType {
 	return ARRAY_OBJECT
 }
 
-// hashmap
+// an array that cannot be changed, a tuple of hashable values can be a hashmap key
+type Tuple struct {
+	Elements []Object
+}
+
+func (t *Tuple) Inspect() string {
+	return inspector{}.value(t)
+}
+
+func (t *Tuple) Type() ObjectType {
+	return TUPLE_OBJ
+}
+
+// (a, b), or (a,) for a single element so it does not read like a grouped expression
+func inspectTuple(t *Tuple, inspect func(Object) string) string {
+	elems := make([]string, len(t.Elements))
+	for i, e := range t.Elements {
+		elems[i] = inspect(e)
+	}
+	if len(elems) == 1 {
+		return "(" + elems[0] + ",)"
+	}
+	return "(" + strings.Join(elems, ", ") + ")"
+}
+
+// values that can be hashmap keys
+type Hashable interface {
+	Object
+	// ok is false when this value cannot be a key after all, like NaN or a tuple holding an array
+	HashKey() (key HashKey, ok bool)
+}
+
+// what a hashmap is keyed by, two keys are the same when their values are equal
+type HashKey struct {
+	Type  ObjectType
+	Value string
+}
+
+func (i *Integer) HashKey() (HashKey, bool) {
+	return HashKey{Type: INTEGER_OBJ, Value: strconv.FormatInt(i.Value, 10)}, true
+}
+
+func (i *BigInteger) HashKey() (HashKey, bool) {
+	return HashKey{Type: BIG_INTEGER_OBJ, Value: i.Value.String()}, true
+}
+
+func (f *Float) HashKey() (HashKey, bool) {
+	if math.IsNaN(f.Value) {
+		// it is not equal to itself, so it could never be found again
+		return HashKey{}, false
+	}
+	v := f.Value
+	if v == 0 {
+		// -0.0 == 0.0
+		v = 0
+	}
+	return HashKey{Type: FLOAT_OBJ, Value: strconv.FormatFloat(v, 'g', -1, 64)}, true
+}
+
+func (b *Boolean) HashKey() (HashKey, bool) {
+	return HashKey{Type: BOOLEAN_OBJ, Value: strconv.FormatBool(b.Value)}, true
+}
+
+func (s *String) HashKey() (HashKey, bool) {
+	return HashKey{Type: STRING, Value: s.Value}, true
+}
+
+func (t *Tuple) HashKey() (HashKey, bool) {
+	var value strings.Builder
+	for _, e := range t.Elements {
+		h, ok := e.(Hashable)
+		if !ok {
+			return HashKey{}, false
+		}
+		key, ok := h.HashKey()
+		if !ok {
+			return HashKey{}, false
+		}
+		// lengths keep ("a", "b") apart from ("a\x00b",) and the like
+		fmt.Fprintf(&value, "%s:%d:%s", key.Type, len(key.Value), key.Value)
+	}
+	return HashKey{Type: TUPLE_OBJ, Value: value.String()}, true
+}
+
+// a key and its value, the key is kept so it can be handed back to scripts
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// hashmap, it keeps its keys in the order they were first added
 type HashMap struct {
-	Pairs map[string]Object
+	index map[HashKey]int // where each key is in pairs
+	pairs []HashPair
+}
+
+func NewHashMap(size int) *HashMap {
+	return &HashMap{index: make(map[HashKey]int, size), pairs: make([]HashPair, 0, size)}
+}
+
+func (h *HashMap) Get(key HashKey) (HashPair, bool) {
+	i, ok := h.index[key]
+	if !ok {
+		return HashPair{}, false
+	}
+	return h.pairs[i], true
+}
+
+// a key that is already there keeps its place
+func (h *HashMap) Set(key HashKey, pair HashPair) {
+	if i, ok := h.index[key]; ok {
+		h.pairs[i] = pair
+		return
+	}
+	h.index[key] = len(h.pairs)
+	h.pairs = append(h.pairs, pair)
+}
+
+func (h *HashMap) Len() int {
+	return len(h.pairs)
+}
+
+// the pairs in insertion order, they must not be modified
+func (h *HashMap) Pairs() []HashPair {
+	return h.pairs
 }
 
 func (h *HashMap) Inspect() string {
-	var result bytes.Buffer
-	result.WriteString("{")
-	var elems []string
-	var current string
-	for k, v := range h.Pairs {
-		current = fmt.Sprintf("%s : %s", k, v.Inspect())
-		elems = append(elems, current)
-	}
-	result.WriteString(strings.Join(elems, ", "))
-	result.WriteString("}")
-	return result.String()
+	return inspector{}.value(h)
+}
+
+// set, like the keys of a HashMap its elements are hashable and kept in the order they were added
+type Set struct {
+	index    map[HashKey]int // where each element is in elements
+	elements []Object
+}
+
+func NewSet(size int) *Set {
+	return &Set{index: make(map[HashKey]int, size), elements: make([]Object, 0, size)}
+}
+
+func (s *Set) Has(key HashKey) bool {
+	_, ok := s.index[key]
+	return ok
+}
+
+// adds elem under key, false when it is already there
+func (s *Set) Add(key HashKey, elem Object) bool {
+	if _, ok := s.index[key]; ok {
+		return false
+	}
+	s.index[key] = len(s.elements)
+	s.elements = append(s.elements, elem)
+	return true
+}
+
+func (s *Set) Len() int {
+	return len(s.elements)
+}
+
+// the elements in insertion order, they must not be modified
+func (s *Set) Elements() []Object {
+	return s.elements
+}
+
+func (s *Set) Inspect() string {
+	if len(s.elements) == 0 {
+		// {} is an empty hashmap
+		return "samuha_muji()"
+	}
+	elems := make([]string, len(s.elements))
+	for i, e := range s.elements {
+		elems[i] = inspector{}.key(e)
+	}
+	return "{" + strings.Join(elems, ", ") + "}"
+}
+
+func (s *Set) Type() ObjectType {
+	return SET_OBJ
+}
+
+// the arrays and hashmaps being printed, one found inside itself prints as [...] or {...}
+type inspector map[Object]bool
+
+func (in inspector) value(obj Object) string {
+	switch o := obj.(type) {
+	case *Array:
+		if in[o] {
+			return "[...]"
+		}
+		in[o] = true
+		defer delete(in, o)
+		elems := make([]string, len(o.Arr))
+		for i, e := range o.Arr {
+			elems[i] = in.value(e)
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case *HashMap:
+		if in[o] {
+			return "{...}"
+		}
+		in[o] = true
+		defer delete(in, o)
+		elems := make([]string, len(o.pairs))
+		for i, pair := range o.pairs {
+			elems[i] = fmt.Sprintf("%s: %s", in.key(pair.Key), in.value(pair.Value))
+		}
+		return "{" + strings.Join(elems, ", ") + "}"
+	case *Tuple:
+		return inspectTuple(o, in.value)
+	default:
+		return obj.Inspect()
+	}
+}
+
+// string keys and set elements are quoted so that {"1": a} and {1: a} print differently
+func (in inspector) key(key Object) string {
+	switch k := key.(type) {
+	case *String:
+		return ast.Quote(k.Value)
+	case *Tuple:
+		return inspectTuple(k, in.key)
+	default:
+		return in.value(key)
+	}
 }
 
 func (h *HashMap) Type() ObjectType {
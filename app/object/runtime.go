@@ -0,0 +1,270 @@
+package object
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Runtime is what a running program can reach of the program hosting it
+// every environment of a program shares the same one, builtins get it with every call
+type Runtime struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Limits Limits
+	stdin  *bufio.Reader
+
+	// float division and modulo by zero raise a ZeroDivisionError instead of giving Inf or NaN
+	StrictFloats bool
+
+	ctx       context.Context
+	steps     int64
+	nextCheck int64 // Step looks at the limits and the context once steps gets here
+	depth     int
+	allocated int64 // since the program was last measured, plus what it held then
+	exhausted bool  // the memory limit was crossed
+	roots     []Root
+}
+
+// Limits bound the work a program may do
+type Limits struct {
+	MaxSteps int64 // nodes evaluated by the tree walker, instructions run by the vm, unbounded when zero
+	MaxDepth int   // nested calls of muji functions, DefaultMaxDepth when zero
+	// bytes of strings, arrays, hashmaps and sets the program may hold at once, unbounded when zero
+	// once allocations add up past it, the values the program can still reach are measured,
+	// so garbage stops counting, a value held only halfway through an expression may be missed
+	MaxMemory int64
+}
+
+// deep enough for any sensible recursion, shallow enough that the tree walker never overflows the go stack
+const DefaultMaxDepth = 10000
+
+// how many steps go by between looks at the context
+const contextCheckInterval = 1024
+
+func NewRuntime(stdout io.Writer, stderr io.Writer, stdin io.Reader) *Runtime {
+	return &Runtime{Stdout: stdout, Stderr: stderr, stdin: bufio.NewReader(stdin)}
+}
+
+// shared by every default runtime, so lines buffered by one are not lost to the next
+var stdin = bufio.NewReader(os.Stdin)
+
+// a runtime talking to the process' own stdout, stderr and stdin
+func DefaultRuntime() *Runtime {
+	return &Runtime{Stdout: os.Stdout, Stderr: os.Stderr, stdin: stdin}
+}
+
+// reads a line from stdin without its line ending, ok is false once stdin is exhausted
+func (rt *Runtime) ReadLine() (string, bool) {
+	line, err := rt.stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimRight(line, "\r\n"), true
+}
+
+// Start resets the step count, call depth and allocations for a new run, which stops once ctx is done
+func (rt *Runtime) Start(ctx context.Context) {
+	rt.ctx = ctx
+	rt.steps = 0
+	rt.depth = 0
+	rt.nextCheck = 0
+	rt.allocated = 0
+	rt.exhausted = false
+}
+
+// Step counts one step of the program and returns the error that stops it once a limit is reached
+// after that every step fails, so samat_muji cannot keep the program running
+func (rt *Runtime) Step() *Error {
+	rt.steps++
+	if rt.steps < rt.nextCheck {
+		return nil
+	}
+	return rt.check()
+}
+
+func (rt *Runtime) check() *Error {
+	if rt.Limits.MaxSteps > 0 && rt.steps > rt.Limits.MaxSteps {
+		return rt.limitError(nil, "step limit of %d exceeded", rt.Limits.MaxSteps)
+	}
+	if rt.ctx != nil {
+		if err := rt.ctx.Err(); err != nil {
+			return rt.limitError(err, "execution stopped: %s", err)
+		}
+	}
+	rt.nextCheck = rt.steps + contextCheckInterval
+	if rt.Limits.MaxSteps > 0 && rt.nextCheck > rt.Limits.MaxSteps+1 {
+		rt.nextCheck = rt.Limits.MaxSteps + 1
+	}
+	return nil
+}
+
+// Enter is called when a muji function is called, Leave when it returns
+func (rt *Runtime) Enter() *Error {
+	maxDepth := rt.Limits.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if rt.depth >= maxDepth {
+		return rt.limitError(nil, "call depth limit of %d exceeded", maxDepth)
+	}
+	rt.depth++
+	return nil
+}
+
+func (rt *Runtime) Leave() {
+	rt.depth--
+}
+
+// Allocate charges n bytes to the program before it allocates them, see the sizes below
+// it returns an error once the memory limit is crossed, and so does every allocation after that
+func (rt *Runtime) Allocate(n int64) *Error {
+	rt.allocated += n
+	if rt.Limits.MaxMemory <= 0 || rt.allocated <= rt.Limits.MaxMemory {
+		return nil
+	}
+	if !rt.exhausted {
+		// much of what was allocated may be garbage by now
+		rt.allocated = rt.live() + n
+		rt.exhausted = rt.allocated > rt.Limits.MaxMemory
+	}
+	if rt.exhausted {
+		return rt.limitError(nil, "memory limit exceeded: more than %d bytes in use", rt.Limits.MaxMemory)
+	}
+	return nil
+}
+
+// Root is where a running program keeps its values: an environment of the tree walker, or the vm
+// objects outside this package that hold other values, like the vm's closures, are Roots too
+type Root interface {
+	EachValue(visit func(Object))
+}
+
+// PushRoot adds r to what the program can reach until the matching PopRoot
+// it returns false, and adds nothing, when r is already the innermost root
+func (rt *Runtime) PushRoot(r Root) bool {
+	if n := len(rt.roots); n > 0 && rt.roots[n-1] == r {
+		return false
+	}
+	rt.roots = append(rt.roots, r)
+	return true
+}
+
+func (rt *Runtime) PopRoot() {
+	rt.roots = rt.roots[:len(rt.roots)-1]
+}
+
+// the bytes held by the values reachable from the roots, each counted once
+func (rt *Runtime) live() int64 {
+	m := measure{seen: map[any]bool{}}
+	for _, r := range rt.roots {
+		m.root(r)
+	}
+	return m.total
+}
+
+// Size is what Allocate would have been charged for obj and the values in it,
+// for values made outside the program, like the results of a go function
+func Size(obj Object) int64 {
+	m := measure{seen: map[any]bool{}}
+	m.value(obj)
+	return m.total
+}
+
+type measure struct {
+	seen  map[any]bool
+	total int64
+}
+
+func (m *measure) root(r Root) {
+	if env, ok := r.(*Environment); ok {
+		m.env(env)
+		return
+	}
+	r.EachValue(m.value)
+}
+
+func (m *measure) env(e *Environment) {
+	for ; e != nil && !m.seen[e]; e = e.outer {
+		m.seen[e] = true
+		for _, v := range e.store {
+			m.value(v)
+		}
+	}
+}
+
+// counts what Allocate was charged for obj and the values in it, keys are part of their pair
+func (m *measure) value(obj Object) {
+	if obj == nil || m.seen[obj] {
+		return
+	}
+	m.seen[obj] = true
+	switch o := obj.(type) {
+	case *String:
+		m.total += StringSize(len(o.Value))
+	case *BigInteger:
+		m.total += BigIntegerSize(o.Value.BitLen())
+	case *Array:
+		m.total += ArraySize(len(o.Arr))
+		for _, e := range o.Arr {
+			m.value(e)
+		}
+	case *Tuple:
+		m.total += ArraySize(len(o.Elements))
+		for _, e := range o.Elements {
+			m.value(e)
+		}
+	case *HashMap:
+		for key := range o.index {
+			m.total += PairSize(len(key.Value))
+		}
+		for _, pair := range o.pairs {
+			m.value(pair.Value)
+		}
+	case *Set:
+		for key := range o.index {
+			m.total += PairSize(len(key.Value))
+		}
+	case *KaamGar:
+		m.env(o.Env)
+	case *Return:
+		m.value(o.Value)
+	case *Exception:
+		m.value(o.Err)
+	case *Error:
+		m.value(o.Value)
+	case Root:
+		o.EachValue(m.value)
+	}
+}
+
+// rough sizes in bytes, good enough to tell a runaway program from a sane one
+const (
+	valueSize = 16 // an Object in an array or hashmap
+	pairSize  = 48 // a hashmap entry, apart from the bytes of its key
+)
+
+func StringSize(length int) int64 {
+	return valueSize + int64(length)
+}
+
+func ArraySize(length int) int64 {
+	return valueSize + valueSize*int64(length)
+}
+
+func PairSize(keyLength int) int64 {
+	return pairSize + int64(keyLength)
+}
+
+func BigIntegerSize(bits int) int64 {
+	return valueSize + int64(bits+7)/8
+}
+
+func (rt *Runtime) limitError(cause error, format string, a ...any) *Error {
+	// fail again on the very next step
+	rt.nextCheck = rt.steps + 1
+	return &Error{Message: fmt.Sprintf(format, a...), Kind: LIMIT_ERROR, Cause: cause}
+}
@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
 
 	"github.com/udeshyadhungana/interprerer/app/ast"
@@ -20,6 +22,8 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 	errors    []string
+	panicking bool // set after an error, cleared once the parser resynchronizes
+	loopDepth int  // number of loops around the current token, reset inside functions
 
 	/* For pratt's parser */
 	prefixParseFns map[token.TokenType]prefixParseFn
@@ -30,29 +34,61 @@ const (
 	_ int = iota
 	LOWEST
 	ASSIGN
+	LOGICAL_OR
+	LOGICAL_AND
 	EQUALS
 	LESSGREATER
+	UNION        // |
+	SYMMETRIC    // ^
+	INTERSECTION // &
 	SUM
 	PRODUCT
 	PREFIX
 	CALL
 )
 
+// tokens that can only appear at the start of a statement
+// the parser resumes from these after an error
+var statementKeywords = map[token.TokenType]bool{
+	token.THOOS_MUJI:      true,
+	token.PATHA_MUJI:      true,
+	token.YEDI_MUJI:       true,
+	token.JABA_SAMMA_MUJI: true,
+	token.GHUMA_MUJI:      true,
+	token.FYAK_MUJI:       true,
+	token.KOSIS_GAR_MUJI:  true,
+	token.RUK_MUJI:        true,
+	token.ARKO_MUJI:       true,
+}
+
+// tokens that continue a statement after its closing brace
+var continuationKeywords = map[token.TokenType]bool{
+	token.NABHAE_MUJI:      true,
+	token.NABHAE_CHIKNE:    true,
+	token.SAMAT_MUJI:       true,
+	token.JASARI_PANI_MUJI: true,
+}
+
 var precedences = map[token.TokenType]int{
-	token.ASSIGN:   ASSIGN,
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.LT_EQ:    LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.GT_EQ:    LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.MOD:      PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: CALL,
+	token.ASSIGN:    ASSIGN,
+	token.OR:        LOGICAL_OR,
+	token.AND:       LOGICAL_AND,
+	token.EQ:        EQUALS,
+	token.NOT_EQ:    EQUALS,
+	token.LT:        LESSGREATER,
+	token.LT_EQ:     LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.GT_EQ:     LESSGREATER,
+	token.PIPE:      UNION,
+	token.CARET:     SYMMETRIC,
+	token.AMPERSAND: INTERSECTION,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.SLASH:     PRODUCT,
+	token.ASTERISK:  PRODUCT,
+	token.MOD:       PRODUCT,
+	token.LPAREN:    CALL,
+	token.LBRACKET:  CALL,
 }
 
 func NewParser(l *lexer.Lexer) *Parser {
@@ -70,10 +106,12 @@ func NewParser(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseLeftParenthesis)
 	p.registerPrefix(token.YEDI_MUJI, p.parseYediMujiExpression)
 	p.registerPrefix(token.STRING, p.parseStringExpression)
+	p.registerPrefix(token.STRING_HEAD, p.parseInterpolatedStringExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArrayExpression)
 	p.registerPrefix(token.JABA_SAMMA_MUJI, p.parseJabasammaMujiExpression)
 	p.registerPrefix(token.GHUMA_MUJI, p.parseGhumaMujiExpression)
 	p.registerPrefix(token.LBRACE, p.parseHashExpression)
+	p.registerPrefix(token.KOSIS_GAR_MUJI, p.parseKosisGarMujiExpression)
 
 	// infix functions for operators
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -88,6 +126,11 @@ func NewParser(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
 	p.registerInfix(token.ASSIGN, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
@@ -100,15 +143,16 @@ func NewParser(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) CheckAndReportErrors() bool {
+	hasErrs := false
 	if len(p.l.Errors()) > 0 {
 		p.l.ReportErrors()
-		return true
+		hasErrs = true
 	}
 	if len(p.errors) > 0 {
 		p.reportErrors()
-		return true
+		hasErrs = true
 	}
-	return false
+	return hasErrs
 }
 
 func (p *Parser) reportErrors() {
@@ -121,9 +165,53 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// records an error message prefixed with the position it occurred at
+// only the first error of a statement is recorded, the rest are usually caused by it
+func (p *Parser) errorAt(pos token.Position, format string, a ...any) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.errors = append(p.errors, fmt.Sprintf("%s: %s", pos, fmt.Sprintf(format, a...)))
+}
+
+// skips tokens after an error until the parser is at the start of the next statement
+// it stops after a ';', after a '}' ending a statement, before a statement keyword,
+// or at a '}' that closes the enclosing block
+func (p *Parser) synchronize() {
+	p.panicking = false
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.SEMICOLON:
+			if depth == 0 {
+				p.nextToken()
+				return
+			}
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth == 0 {
+				return
+			}
+			depth--
+			// a block we skipped ends the statement unless the statement goes on after it
+			if depth == 0 && p.peekToken.Pos.Line > p.curToken.Pos.Line &&
+				!p.peekTokenIs(token.SEMICOLON) && !continuationKeywords[p.peekToken.Type] {
+				p.nextToken()
+				return
+			}
+		}
+		if depth == 0 && statementKeywords[p.peekToken.Type] {
+			p.nextToken()
+			return
+		}
+		p.nextToken()
+	}
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.peekToken.Pos, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) nextToken() {
@@ -163,8 +251,13 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for p.curToken.Type != token.EOF {
 		stmt := p.parseStatement()
-		if len(p.errors) > 0 {
-			return nil
+		if p.panicking {
+			p.synchronize()
+			// nothing encloses the top level, so a '}' here is stray
+			if p.curTokenIs(token.RBRACE) {
+				p.nextToken()
+			}
+			continue
 		}
 		program.Statements = append(program.Statements, stmt)
 		p.nextToken()
@@ -176,16 +269,24 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	bs := &ast.BlockStatement{Token: p.curToken}
 	s := []ast.Statement{}
 	if !p.curTokenIs(token.LBRACE) {
-		p.errors = append(p.errors, "expected { at the beginning of block statement")
+		p.errorAt(p.curToken.Pos, "expected { at the beginning of block statement")
 		return nil
 	}
 	p.nextToken()
 	for p.curToken.Type != token.RBRACE && p.curToken.Type != token.EOF {
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize()
+			continue
+		}
 		s = append(s, stmt)
 		p.nextToken()
 	}
+	if p.curTokenIs(token.EOF) {
+		p.errorAt(p.curToken.Pos, "expected } at the end of block statement")
+	}
 	bs.Statements = s
+	bs.Rbrace = p.curToken
 	return bs
 }
 
@@ -195,6 +296,12 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseThoosMujiStatement()
 	case token.PATHA_MUJI:
 		return p.parsePathaMujiStatement()
+	case token.FYAK_MUJI:
+		return p.parseFyakMujiStatement()
+	case token.RUK_MUJI:
+		return p.parseRukMujiStatement()
+	case token.ARKO_MUJI:
+		return p.parseArkoMujiStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -204,14 +311,19 @@ func (p *Parser) parseThoosMujiStatement() *ast.ThoosMujiStatement {
 	stmt := &ast.ThoosMujiStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDFIER) {
-		p.errors = append(p.errors, "expected identifier after thoos_muji")
+		p.errorAt(p.curToken.Pos, "expected identifier after thoos_muji")
 		return nil
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return p.parseThoosMujiValue(stmt.Token, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+}
+
+// parses the rest of a thoos_muji statement once its name has been read
+func (p *Parser) parseThoosMujiValue(tok token.Token, name *ast.Identifier) *ast.ThoosMujiStatement {
+	stmt := &ast.ThoosMujiStatement{Token: tok, Name: name}
 
 	if !p.expectPeek(token.ASSIGN) {
-		p.errors = append(p.errors, "expected = after identifier")
+		p.errorAt(p.curToken.End, "expected = after identifier")
 		return nil
 	}
 	p.nextToken()
@@ -221,7 +333,7 @@ func (p *Parser) parseThoosMujiStatement() *ast.ThoosMujiStatement {
 		p.nextToken()
 	}
 	if !p.curTokenIs(token.SEMICOLON) {
-		p.errors = append(p.errors, "expected semicolon at the end of statement")
+		p.errorAt(p.curToken.End, "expected semicolon at the end of statement")
 		return nil
 	}
 
@@ -238,21 +350,109 @@ func (p *Parser) parsePathaMujiStatement() *ast.PathaMujiStatement {
 		p.nextToken()
 	}
 	if !p.curTokenIs(token.SEMICOLON) {
-		p.errors = append(p.errors, "expected semicolon at the end of statement")
+		p.errorAt(p.curToken.End, "expected semicolon at the end of statement")
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseFyakMujiStatement() *ast.FyakMujiStatement {
+	stmt := &ast.FyakMujiStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Value = p.parseExpressionUsingPratt(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	if !p.curTokenIs(token.SEMICOLON) {
+		p.errorAt(p.curToken.End, "expected semicolon at the end of statement")
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseRukMujiStatement() *ast.RukMujiStatement {
+	stmt := &ast.RukMujiStatement{Token: p.curToken}
+	if p.loopDepth == 0 {
+		p.errorAt(p.curToken.Pos, "ruk_muji used outside of a loop")
+		return nil
 	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+	return stmt
+}
 
+func (p *Parser) parseArkoMujiStatement() *ast.ArkoMujiStatement {
+	stmt := &ast.ArkoMujiStatement{Token: p.curToken}
+	if p.loopDepth == 0 {
+		p.errorAt(p.curToken.Pos, "arko_muji used outside of a loop")
+		return nil
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
 	return stmt
 }
 
+// parses the body of a loop, where ruk_muji and arko_muji are allowed
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseKosisGarMujiExpression() ast.Expression {
+	expr := &ast.KosisGarMujiExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expr.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.SAMAT_MUJI) {
+		p.nextToken()
+		// binding the caught error is optional
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if !p.expectPeek(token.IDFIER) {
+				return nil
+			}
+			expr.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expr.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.JASARI_PANI_MUJI) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expr.Finally = p.parseBlockStatement()
+	}
+
+	if expr.Catch == nil && expr.Finally == nil {
+		p.errorAt(p.peekToken.Pos, "expected samat_muji or jasari_pani_muji after kosis_gar_muji block")
+		return nil
+	}
+	return expr
+}
+
 func (p *Parser) parseYediMujiExpression() ast.Expression {
 	stmt := &ast.YediMujiExpression{Token: p.curToken}
 	if !p.curTokenIs(token.YEDI_MUJI) {
-		p.errors = append(p.errors, "expected yedi_muji at the start of yedi_muji expression")
+		p.errorAt(p.curToken.Pos, "expected yedi_muji at the start of yedi_muji expression")
 		return nil
 	}
 	p.nextToken()
 	if !p.curTokenIs(token.LPAREN) {
-		p.errors = append(p.errors, "expected ( after yedi_muji")
+		p.errorAt(p.curToken.Pos, "expected ( after yedi_muji")
 		return nil
 	}
 	// evaluate expression
@@ -260,7 +460,7 @@ func (p *Parser) parseYediMujiExpression() ast.Expression {
 	stmt.Condition = p.parseExpressionUsingPratt(LOWEST)
 	p.nextToken()
 	if !p.curTokenIs(token.RPAREN) && !p.peekTokenIs(token.LBRACE) {
-		p.errors = append(p.errors, "expected ) after condition and block statement after that")
+		p.errorAt(p.curToken.Pos, "expected ) after condition and block statement after that")
 		return nil
 	}
 	p.nextToken()
@@ -292,7 +492,7 @@ func (p *Parser) parseYediMujiExpression() ast.Expression {
 func (p *Parser) parseNabhaeMujiExpression() *ast.NabhaeMujiExpression {
 	res := &ast.NabhaeMujiExpression{Token: p.curToken}
 	if !p.curTokenIs(token.NABHAE_MUJI) {
-		p.errors = append(p.errors, "yedi muji alternative expression must begin with `nabhae_muji`")
+		p.errorAt(p.curToken.Pos, "yedi muji alternative expression must begin with `nabhae_muji`")
 	}
 	if !p.expectPeek(token.LPAREN) {
 		return nil
@@ -315,12 +515,12 @@ func (p *Parser) parseNabhaeMujiExpression() *ast.NabhaeMujiExpression {
 func (p *Parser) parseJabasammaMujiExpression() ast.Expression {
 	stmt := &ast.JabasammaMujiExpression{Token: p.curToken}
 	if !p.curTokenIs(token.JABA_SAMMA_MUJI) {
-		p.errors = append(p.errors, "jaba_samma_muji expected")
+		p.errorAt(p.curToken.Pos, "jaba_samma_muji expected")
 		return nil
 	}
 	p.nextToken()
 	if !p.curTokenIs(token.LPAREN) {
-		p.errors = append(p.errors, "expected ( after jaba_samma_muji")
+		p.errorAt(p.curToken.Pos, "expected ( after jaba_samma_muji")
 		return nil
 	}
 	// parse condition
@@ -328,51 +528,91 @@ func (p *Parser) parseJabasammaMujiExpression() ast.Expression {
 	stmt.Condition = p.parseExpressionUsingPratt(LOWEST)
 	p.nextToken()
 	if !p.curTokenIs(token.RPAREN) && !p.peekTokenIs(token.LBRACE) {
-		p.errors = append(p.errors, "expected sequence ) {")
+		p.errorAt(p.curToken.Pos, "expected sequence ) {")
 		return nil
 	}
 	p.nextToken()
-	stmt.Consequent = p.parseBlockStatement()
+	stmt.Consequent = p.parseLoopBody()
 	return stmt
 }
 
 func (p *Parser) parseGhumaMujiExpression() ast.Expression {
 	stmt := &ast.GhumaMujiExpression{Token: p.curToken}
 	if !p.curTokenIs(token.GHUMA_MUJI) {
-		p.errors = append(p.errors, "expected: ghuma_muji")
+		p.errorAt(p.curToken.Pos, "expected: ghuma_muji")
 		return nil
 	}
 	p.nextToken()
 	if !p.curTokenIs(token.LPAREN) {
-		p.errors = append(p.errors, "expected '(' after ghuma_muji")
+		p.errorAt(p.curToken.Pos, "expected '(' after ghuma_muji")
 		return nil
 	}
 	p.nextToken()
-	stmt.Initialization = p.parseStatement()
+	if p.curTokenIs(token.THOOS_MUJI) && p.peekTokenIs(token.IDFIER) {
+		thoos := p.curToken
+		p.nextToken()
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if p.peekTokenIs(token.COLON) || p.peekTokenIs(token.COMMA) {
+			return p.parseGhumaMujiEachExpression(stmt.Token, name)
+		}
+		stmt.Initialization = p.parseThoosMujiValue(thoos, name)
+	} else {
+		stmt.Initialization = p.parseStatement()
+	}
 	if !p.curTokenIs(token.SEMICOLON) {
-		p.errors = append(p.errors, "expected semicolon after initialization")
+		p.errorAt(p.curToken.Pos, "expected semicolon after initialization")
 		return nil
 	}
 	p.nextToken()
 	stmt.Condition = p.parseStatement()
 	if !p.curTokenIs(token.SEMICOLON) {
-		p.errors = append(p.errors, "expected semicolon after condition")
+		p.errorAt(p.curToken.Pos, "expected semicolon after condition")
 		return nil
 	}
 	p.nextToken()
 	stmt.Update = p.parseExpressionUsingPratt(LOWEST)
 	if !p.expectPeek(token.RPAREN) {
-		p.errors = append(p.errors, "expected ')' after update in ghuma_muji")
+		p.errorAt(p.curToken.End, "expected ')' after update in ghuma_muji")
 		return nil
 	}
 	if !p.expectPeek(token.LBRACE) {
-		p.errors = append(p.errors, "expected { for ghuma_muji body")
+		p.errorAt(p.curToken.End, "expected { for ghuma_muji body")
 		return nil
 	}
-	stmt.Body = p.parseBlockStatement()
+	stmt.Body = p.parseLoopBody()
 	return stmt
 }
 
+// parses the range form of ghuma_muji; curToken is the first loop variable
+func (p *Parser) parseGhumaMujiEachExpression(tok token.Token, first *ast.Identifier) ast.Expression {
+	expr := &ast.GhumaMujiEachExpression{Token: tok, Value: first}
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDFIER) {
+			p.errorAt(p.curToken.Pos, "expected identifier after ',' in ghuma_muji")
+			return nil
+		}
+		expr.Key = first
+		expr.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	if !p.expectPeek(token.COLON) {
+		p.errorAt(p.curToken.End, "expected ':' after loop variables in ghuma_muji")
+		return nil
+	}
+	p.nextToken()
+	expr.Iterable = p.parseExpressionUsingPratt(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		p.errorAt(p.curToken.End, "expected ')' after iterable in ghuma_muji")
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		p.errorAt(p.curToken.End, "expected { for ghuma_muji body")
+		return nil
+	}
+	expr.Body = p.parseLoopBody()
+	return expr
+}
+
 /* expressions */
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
@@ -422,9 +662,15 @@ func (p *Parser) parseBoolean() ast.Expression {
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// too big for an int64
+		if v, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = v
+			return lit
+		}
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.numberError(err, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 	lit.Value = value
@@ -435,17 +681,24 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	lit := &ast.FloatLiteral{Token: p.curToken}
 	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.numberError(err, "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 	lit.Value = value
 	return lit
 }
 
+// a malformed literal has already been reported by the lexer, only its value can still be wrong
+func (p *Parser) numberError(err error, format string, a ...any) {
+	if errors.Is(err, strconv.ErrSyntax) {
+		p.panicking = true
+		return
+	}
+	p.errorAt(p.curToken.Pos, format, a...)
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for (%s) found", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken.Pos, "no prefix parse function for (%s) found", t)
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -470,19 +723,48 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// a grouped expression, or a tuple when there is a comma or nothing inside
 func (p *Parser) parseLeftParenthesis() ast.Expression {
+	lparen := p.curToken
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return &ast.TupleExpression{Token: lparen, Rparen: p.curToken}
+	}
 	p.nextToken()
 	exp := p.parseExpressionUsingPratt(LOWEST)
+	if p.peekTokenIs(token.COMMA) {
+		return p.parseTupleExpression(lparen, exp)
+	}
 	if !p.expectPeek(token.RPAREN) {
-		p.errors = append(p.errors, "mismatched parenthesis")
+		p.errorAt(p.curToken.End, "mismatched parenthesis")
 		return nil
 	}
 	return exp
 }
 
+// the rest of a tuple after its first element, a trailing comma is allowed
+func (p *Parser) parseTupleExpression(lparen token.Token, first ast.Expression) ast.Expression {
+	result := &ast.TupleExpression{Token: lparen, Elements: []ast.Expression{first}}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
+		p.nextToken()
+		result.Elements = append(result.Elements, p.parseExpressionUsingPratt(LOWEST))
+	}
+	if !p.expectPeek(token.RPAREN) {
+		p.errorAt(p.curToken.End, "expected ) at the end of tuple")
+		return nil
+	}
+	result.Rparen = p.curToken
+	return result
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	result := ast.CallExpression{Token: p.curToken, Function: function}
 	result.Arguments = p.parseArguments()
+	result.Rparen = p.curToken
 	return &result
 }
 
@@ -500,7 +782,7 @@ func (p *Parser) parseArguments() []ast.Expression {
 		args = append(args, p.parseExpressionUsingPratt(LOWEST))
 	}
 	if !p.expectPeek(token.RPAREN) {
-		p.errors = append(p.errors, "expected ) after args list")
+		p.errorAt(p.curToken.End, "expected ) after args list")
 		return nil
 	}
 	return args
@@ -509,12 +791,12 @@ func (p *Parser) parseArguments() []ast.Expression {
 func (p *Parser) parseKaamGarMuji() ast.Expression {
 	result := ast.KaamGarMujiExpression{Token: p.curToken}
 	if !p.curTokenIs(token.KAAM_GAR_MUJI) {
-		p.errors = append(p.errors, "expected: kaam_gar_muji")
+		p.errorAt(p.curToken.Pos, "expected: kaam_gar_muji")
 		return nil
 	}
 	p.nextToken()
 	if !p.curTokenIs(token.LPAREN) {
-		p.errors = append(p.errors, "expected ( after kaam_gar_muji")
+		p.errorAt(p.curToken.Pos, "expected ( after kaam_gar_muji")
 		return nil
 	}
 	p.nextToken()
@@ -525,7 +807,7 @@ func (p *Parser) parseKaamGarMuji() ast.Expression {
 			currentArg := p.parseIdentifier()
 			r, ok := currentArg.(*ast.Identifier)
 			if !ok {
-				p.errors = append(p.errors, "parameters must be identifiers")
+				p.errorAt(p.curToken.Pos, "parameters must be identifiers")
 			}
 			result.Arguments = append(result.Arguments, r)
 			p.nextToken()
@@ -533,14 +815,18 @@ func (p *Parser) parseKaamGarMuji() ast.Expression {
 				break
 			}
 			if !p.curTokenIs(token.COMMA) {
-				p.errors = append(p.errors, "expected comma after argument")
+				p.errorAt(p.curToken.Pos, "expected comma after argument")
 				return nil
 			}
 			p.nextToken()
 		}
 	}
 	p.nextToken()
+	// loops outside the function cannot be left from inside it
+	outerLoopDepth := p.loopDepth
+	p.loopDepth = 0
 	result.Body = p.parseBlockStatement()
+	p.loopDepth = outerLoopDepth
 	return &result
 }
 
@@ -548,11 +834,36 @@ func (p *Parser) parseStringExpression() ast.Expression {
 	return &ast.StringExpression{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedStringExpression() ast.Expression {
+	result := &ast.InterpolatedStringExpression{Token: p.curToken, Texts: []string{p.curToken.Literal}}
+	for {
+		if p.peekTokenIs(token.STRING_MID) || p.peekTokenIs(token.STRING_TAIL) {
+			p.errorAt(p.peekToken.Pos, "empty interpolation in string")
+			return nil
+		}
+		p.nextToken()
+		result.Values = append(result.Values, p.parseExpressionUsingPratt(LOWEST))
+		p.nextToken()
+		result.Texts = append(result.Texts, p.curToken.Literal)
+		switch p.curToken.Type {
+		case token.STRING_MID:
+		case token.STRING_TAIL:
+			result.Tail = p.curToken
+			return result
+		default:
+			p.errorAt(p.curToken.Pos, "expected } at the end of interpolation, got %s instead", p.curToken.Type)
+			return nil
+		}
+	}
+}
+
 func (p *Parser) parseArrayExpression() ast.Expression {
 	result := &ast.ArrayExpression{Token: p.curToken}
 	var current ast.Expression
 
 	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		result.Rbracket = p.curToken
 		return result
 	}
 	p.nextToken()
@@ -565,27 +876,63 @@ func (p *Parser) parseArrayExpression() ast.Expression {
 		} else if p.curTokenIs(token.RBRACKET) {
 			break
 		} else {
-			p.errors = append(p.errors, "malformed array expression")
+			p.errorAt(p.curToken.Pos, "malformed array expression")
 			return nil
 		}
 	}
+	result.Rbracket = p.curToken
 	return result
 }
 
+// operand[index], or the slice operand[low:high]
 func (p *Parser) parseIndexExpression(expr ast.Expression) ast.Expression {
-	result := &ast.IndexExpression{Token: p.curToken, Operand: expr}
-	p.nextToken()
-	indexExpr := p.parseExpressionUsingPratt(LOWEST)
-	p.nextToken()
+	lbracket := p.curToken
+	var indexExpr ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		indexExpr = p.parseExpressionUsingPratt(LOWEST)
+	}
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(lbracket, expr, indexExpr)
+	}
+	result := &ast.IndexExpression{Token: lbracket, Operand: expr}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
 	result.Index = indexExpr
+	result.Rbracket = p.curToken
+	return result
+}
+
+// parses the rest of a slice once its low bound has been read, the current token is before the ':'
+func (p *Parser) parseSliceExpression(lbracket token.Token, operand ast.Expression, low ast.Expression) ast.Expression {
+	result := &ast.SliceExpression{Token: lbracket, Operand: operand, Low: low}
+	p.nextToken()
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		result.High = p.parseExpressionUsingPratt(LOWEST)
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	result.Rbracket = p.curToken
 	return result
 }
 
+// {k: v, ...}, {} for an empty hashmap, or the set {a, b, ...}
 func (p *Parser) parseHashExpression() ast.Expression {
 	result := &ast.HashExpression{Token: p.curToken, Pairs: make(map[ast.Expression]ast.Expression)}
+	if p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		result.Rbrace = p.curToken
+		return result
+	}
 	for !p.curTokenIs(token.RBRACE) {
 		p.nextToken()
 		k := p.parseExpressionUsingPratt(LOWEST)
+		if len(result.Pairs) == 0 && !p.peekTokenIs(token.COLON) {
+			return p.parseSetExpression(result.Token, k)
+		}
 		if !p.expectPeek(token.COLON) {
 			return nil
 		}
@@ -594,6 +941,23 @@ func (p *Parser) parseHashExpression() ast.Expression {
 		result.Pairs[k] = v
 		p.nextToken()
 	}
+	result.Rbrace = p.curToken
+	return result
+}
+
+// the rest of a set after its first element
+func (p *Parser) parseSetExpression(lbrace token.Token, first ast.Expression) ast.Expression {
+	result := &ast.SetExpression{Token: lbrace, Elements: []ast.Expression{first}}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		result.Elements = append(result.Elements, p.parseExpressionUsingPratt(LOWEST))
+	}
+	if !p.expectPeek(token.RBRACE) {
+		p.errorAt(p.curToken.End, "expected } at the end of set")
+		return nil
+	}
+	result.Rbrace = p.curToken
 	return result
 }
 
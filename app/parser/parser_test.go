@@ -462,6 +462,22 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g);",
 			"add((((a + b) + ((c * d) / f)) + g));",
 		},
+		{
+			"a || b && c;",
+			"(a || (b && c));",
+		},
+		{
+			"a && b || c && d;",
+			"((a && b) || (c && d));",
+		},
+		{
+			"x != 1 && x[0] > 1;",
+			"((x != 1) && (x[0] > 1));",
+		},
+		{
+			"x = a || b;",
+			"(x = (a || b));",
+		},
 	}
 
 	for _, tt := range tests {
@@ -595,6 +611,35 @@ func TestString(t *testing.T) {
 	}
 }
 
+func TestInterpolatedString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"total: ${sum}";`, `"total: ${sum}"`},
+		{`"${a + b * c}${d}";`, `"${(a + (b * c))}${d}"`},
+		{`"a ${f(x, "y ${z}")} b";`, `"a ${f(x, "y ${z}")} b"`},
+		{`"${ {"k": 1}["k"] } \${x}\n";`, `"${{"k": 1}["k"]} \${x}\n"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("expected 1 statement. got %d", len(program.Statements))
+		}
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.InterpolatedStringExpression); !ok {
+			t.Fatalf("expected *ast.InterpolatedStringExpression. got=%T", stmt.Expression)
+		}
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("wrong string. expected=%s, got=%s", tt.expected, stmt.Expression.String())
+		}
+	}
+}
+
 func TestArrayLength(t *testing.T) {
 	tests := []struct {
 		statement string
@@ -694,6 +739,106 @@ func TestHashIndex(t *testing.T) {
 	}
 }
 
+func TestSliceExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:3];", "a[1:3]"},
+		{"a[:-1];", "a[:(-1)]"},
+		{"s[2:];", "s[2:]"},
+		{"s[:];", "s[:]"},
+		{"f(x)[i + 1:j * 2][0];", "f(x)[(i + 1):(j * 2)][0]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("wrong string. expected=%s, got=%s", tt.expected, stmt.Expression.String())
+		}
+	}
+
+	l := lexer.NewLexer("a[1:2:3];")
+	p := NewParser(l)
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "1:6: expected next token to be ], got : instead" {
+		t.Errorf("wrong errors. got=%q", p.Errors())
+	}
+}
+
+func TestTupleExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(1, 2);", "(1, 2)"},
+		{"(a + b, c);", "((a + b), c)"},
+		{"(a,);", "(a,)"},
+		{"(1, 2,);", "(1, 2)"},
+		{"();", "()"},
+		{"(a);", "a"},
+		{"((1, 2), (3,));", "((1, 2), (3,))"},
+		{"{(1, 2): x};", "{(1, 2): x}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("wrong string. expected=%s, got=%s", tt.expected, stmt.Expression.String())
+		}
+	}
+
+	l := lexer.NewLexer("(1, 2;")
+	p := NewParser(l)
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "1:6: expected next token to be ), got ; instead" {
+		t.Errorf("wrong errors. got=%q", p.Errors())
+	}
+}
+
+func TestSetExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{1, 2, 3};", "{1, 2, 3}"},
+		{"{a};", "{a}"},
+		{"{a + 1, (b, c)};", "{(a + 1), (b, c)}"},
+		{"{};", "{}"},
+		{"a | b & c;", "(a | (b & c))"},
+		{"a ^ b | c;", "((a ^ b) | c)"},
+		{"a & b ^ c & d;", "((a & b) ^ (c & d))"},
+		{"a - b & c;", "((a - b) & c)"},
+		{"a | b == c;", "((a | b) == c)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("wrong string. expected=%s, got=%s", tt.expected, stmt.Expression.String())
+		}
+	}
+
+	l := lexer.NewLexer("{1, 2;")
+	p := NewParser(l)
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "1:6: expected next token to be }, got ; instead" {
+		t.Errorf("wrong errors. got=%q", p.Errors())
+	}
+}
+
 func TestJabasammaMujiExpressionParsing(t *testing.T) {
 	tests := []struct {
 		program  string
@@ -734,6 +879,167 @@ func TestJabasammaMujiExpressionParsing(t *testing.T) {
 	}
 }
 
+func TestGhumaMujiEachExpressionParsing(t *testing.T) {
+	tests := []struct {
+		program  string
+		key      string
+		value    string
+		expected string
+	}{
+		{
+			`ghuma_muji (thoos_muji x : arr) { bhan_muji(x); }`,
+			"",
+			"x",
+			"ghuma_muji (thoos_muji x : arr){\n\tbhan_muji(x);\n}",
+		},
+		{
+			`ghuma_muji (thoos_muji k, v : {"a": 1}) { k; }`,
+			"k",
+			"v",
+			"ghuma_muji (thoos_muji k, v : {\"a\": 1}){\n\tk;\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.program)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+		}
+		es, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		each, ok := es.Expression.(*ast.GhumaMujiEachExpression)
+		if !ok {
+			t.Fatalf("expression not an ast.GhumaMujiEachExpression. got=%T", es.Expression)
+		}
+		if tt.key == "" && each.Key != nil {
+			t.Errorf("expected no key variable, got=%s", each.Key)
+		}
+		if tt.key != "" && (each.Key == nil || each.Key.Value != tt.key) {
+			t.Errorf("key variable wrong. expected=%s, got=%v", tt.key, each.Key)
+		}
+		if each.Value.Value != tt.value {
+			t.Errorf("value variable wrong. expected=%s, got=%s", tt.value, each.Value.Value)
+		}
+		if each.String() != tt.expected {
+			t.Errorf("String() wrong. expected=%q, got=%q", tt.expected, each.String())
+		}
+	}
+
+	errorTests := []struct {
+		program  string
+		expected string
+	}{
+		{`ghuma_muji (thoos_muji k, : arr) {}`, "1:27: expected next token to be IDENTIFIER, got : instead"},
+		{`ghuma_muji (thoos_muji x : arr {}`, "1:32: expected next token to be ), got { instead"},
+	}
+	for _, tt := range errorTests {
+		l := lexer.NewLexer(tt.program)
+		p := NewParser(l)
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected first=%q, got=%q", tt.program, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestKosisGarMujiExpressionParsing(t *testing.T) {
+	tests := []struct {
+		program         string
+		expectedParam   string
+		expectedCatch   bool
+		expectedFinally bool
+	}{
+		{`kosis_gar_muji { 1; } samat_muji (e) { 2; }`, "e", true, false},
+		{`kosis_gar_muji { 1; } samat_muji { 2; }`, "", true, false},
+		{`kosis_gar_muji { 1; } jasari_pani_muji { 3; }`, "", false, true},
+		{`kosis_gar_muji { 1; } samat_muji (err) { 2; } jasari_pani_muji { 3; }`, "err", true, true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.program)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+		}
+		es, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		k, ok := es.Expression.(*ast.KosisGarMujiExpression)
+		if !ok {
+			t.Fatalf("expression not an ast.KosisGarMujiExpression. got=%T", es.Expression)
+		}
+		if tt.expectedParam == "" && k.Param != nil {
+			t.Errorf("expected no catch parameter, got=%s", k.Param)
+		}
+		if tt.expectedParam != "" && (k.Param == nil || k.Param.Value != tt.expectedParam) {
+			t.Errorf("wrong catch parameter. expected=%s, got=%v", tt.expectedParam, k.Param)
+		}
+		if (k.Catch != nil) != tt.expectedCatch {
+			t.Errorf("samat_muji block presence wrong. expected=%t", tt.expectedCatch)
+		}
+		if (k.Finally != nil) != tt.expectedFinally {
+			t.Errorf("jasari_pani_muji block presence wrong. expected=%t", tt.expectedFinally)
+		}
+	}
+}
+
+func TestFyakMujiStatement(t *testing.T) {
+	l := lexer.NewLexer(`fyak_muji "oops"; kosis_gar_muji { 1; }`)
+	p := NewParser(l)
+	program := p.ParseProgram()
+
+	if _, ok := program.Statements[0].(*ast.FyakMujiStatement); !ok {
+		t.Fatalf("program.Statements[0] is not ast.FyakMujiStatement. got=%T", program.Statements[0])
+	}
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected 1 error for kosis_gar_muji without samat_muji or jasari_pani_muji. got=%q", p.Errors())
+	}
+}
+
+func TestLoopControlParsing(t *testing.T) {
+	tests := []struct {
+		program        string
+		expectedErrors []string
+	}{
+		{`jaba_samma_muji (sacho_muji) { ruk_muji; arko_muji; }`, nil},
+		{`ghuma_muji (thoos_muji i = 0; i < 3; i = i + 1) { yedi_muji (i == 1) { arko_muji; } }`, nil},
+		{`ruk_muji;`, []string{"1:1: ruk_muji used outside of a loop"}},
+		{`yedi_muji (sacho_muji) { arko_muji; }`, []string{"1:26: arko_muji used outside of a loop"}},
+		{
+			`jaba_samma_muji (sacho_muji) {
+				thoos_muji f = kaam_gar_muji() { ruk_muji; };
+				ruk_muji;
+			}`,
+			[]string{"2:38: ruk_muji used outside of a loop"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexer(tt.program)
+		p := NewParser(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) != len(tt.expectedErrors) {
+			t.Fatalf("wrong number of errors for %q. expected=%d, got=%q", tt.program, len(tt.expectedErrors), p.Errors())
+		}
+		for i, msg := range tt.expectedErrors {
+			if p.Errors()[i] != msg {
+				t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, msg, p.Errors()[i])
+			}
+		}
+	}
+}
+
 func TestHashExpressionParsing(t *testing.T) {
 	tests := []struct {
 		program    string
@@ -854,3 +1160,127 @@ func TestCustom(t *testing.T) {
 		checkParserErrors(t, p)
 	}
 }
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"thoos_muji x = 5\nthoos_muji y = 3;", "f.muji:1:17: expected semicolon at the end of statement"},
+		{"thoos_muji = 5;", "f.muji:1:12: expected next token to be IDENTIFIER, got = instead"},
+		{"x + ;", "f.muji:1:5: no prefix parse function for (;) found"},
+		{`"a ${} b";`, "f.muji:1:6: empty interpolation in string"},
+		{"thoos_muji x = 1e400;", "f.muji:1:16: could not parse \"1e400\" as float"},
+		{`"a ${x y} b";`, "f.muji:1:8: expected } at the end of interpolation, got IDENTIFIER instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewLexerWithFile("f.muji", tt.input)
+		p := NewParser(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+		if p.Errors()[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, p.Errors()[0])
+		}
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	input := "thoos_muji x = 1.2.3;\nthoos_muji y = 0x;\nthoos_muji z = 1e3;"
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+
+	// the lexer reports them, the parser only skips the statements
+	if len(p.Errors()) != 0 {
+		t.Errorf("expected no parser errors. got=%q", p.Errors())
+	}
+	if len(l.Errors()) != 2 {
+		t.Errorf("expected 2 lexer errors. got=%q", l.Errors())
+	}
+	if len(program.Statements) != 1 || program.Statements[0].String() != "thoos_muji z = 1e3;" {
+		t.Errorf("wrong statements. got=%q", program.String())
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `thoos_muji add = kaam_gar_muji(x, y) {
+	patha_muji x + y;
+};
+add(1, [2, 3][0]);`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	tests := []struct {
+		node        ast.Node
+		expectedPos string
+		expectedEnd string
+	}{
+		{program, "1:1", "4:18"},
+		{program.Statements[0], "1:1", "3:2"},
+		{program.Statements[0].(*ast.ThoosMujiStatement).Value, "1:18", "3:2"},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression, "4:1", "4:18"},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression).Arguments[1], "4:8", "4:17"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedPos {
+			t.Errorf("tests[%d] - wrong position. expected=%s, got=%s", i, tt.expectedPos, tt.node.Pos())
+		}
+		if tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("tests[%d] - wrong end position. expected=%s, got=%s", i, tt.expectedEnd, tt.node.End())
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := `thoos_muji x = 5
+thoos_muji y = ;
+thoos_muji f = kaam_gar_muji(a) {
+	thoos_muji z = a +;
+	yedi_muji (z > 1 {
+		bhan_muji("big");
+	}
+	patha_muji z;
+};
+ghuma_muji(thoos_muji i = 0; i < 10; i = i + 1 {
+	bhan_muji(i);
+}
+bhan_muji(x) )
+thoos_muji ok = 1;`
+
+	expected := []string{
+		"1:17: expected semicolon at the end of statement",
+		"2:16: no prefix parse function for (;) found",
+		"4:20: no prefix parse function for (;) found",
+		"5:19: expected ) after condition and block statement after that",
+		"10:48: expected next token to be ), got { instead",
+		"13:14: no prefix parse function for ()) found",
+	}
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != len(expected) {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d (%q)", len(expected), len(p.Errors()), p.Errors())
+	}
+	for i, msg := range expected {
+		if p.Errors()[i] != msg {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, msg, p.Errors()[i])
+		}
+	}
+
+	// statements after the last error are still parsed
+	last := program.Statements[len(program.Statements)-1]
+	if !testThoosMujiStatement(t, last, "ok") {
+		return
+	}
+}
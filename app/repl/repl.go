@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/udeshyadhungana/interprerer/app/compiler"
 	"github.com/udeshyadhungana/interprerer/app/eval"
 	"github.com/udeshyadhungana/interprerer/app/lexer"
 	"github.com/udeshyadhungana/interprerer/app/object"
 	"github.com/udeshyadhungana/interprerer/app/parser"
 	"github.com/udeshyadhungana/interprerer/app/utils"
+	"github.com/udeshyadhungana/interprerer/app/vm"
 )
 
 const PROMPT = "(lekh_muji) >> "
@@ -29,15 +31,62 @@ func Start(in io.Reader, out io.Writer) {
 		p := parser.NewParser(l)
 
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			utils.PrintParserErrors(out, p.Errors())
+		// a malformed string or number is reported by the lexer, the parser carries on past it
+		if errs := append(l.Errors(), p.Errors()...); len(errs) != 0 {
+			utils.PrintParserErrors(out, errs)
 			continue
 		}
 
 		evaluated := eval.Eval(program, env)
-		if evaluated != nil && evaluated.Type() != object.NULL_OBJ {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		printResult(out, evaluated)
+	}
+}
+
+// like Start, but every line is compiled to bytecode and run on the vm
+func StartVM(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	symbols := compiler.NewSymbolTable()
+	var constants []object.Object
+	var globals []object.Object
+
+	for {
+		fmt.Print(PROMPT)
+		scanned := scanner.Scan()
+		if !scanned {
+			return
+		}
+		line := scanner.Text()
+		l := lexer.NewLexer(line)
+		p := parser.NewParser(l)
+
+		program := p.ParseProgram()
+		// a malformed string or number is reported by the lexer, the parser carries on past it
+		if errs := append(l.Errors(), p.Errors()...); len(errs) != 0 {
+			utils.PrintParserErrors(out, errs)
+			continue
+		}
+
+		c := compiler.NewWithState(symbols, constants)
+		if err := c.Compile(program); err != nil {
+			io.WriteString(out, "\t"+err.Error()+"\n")
+			continue
 		}
+		bytecode := c.Bytecode()
+		constants = bytecode.Constants
+
+		machine := vm.NewWithGlobals(bytecode, globals)
+		evaluated := machine.Run()
+		globals = machine.Globals()
+		printResult(out, evaluated)
+	}
+}
+
+func printResult(out io.Writer, evaluated object.Object) {
+	if err, ok := evaluated.(*object.Error); ok {
+		io.WriteString(out, err.Traceback())
+		io.WriteString(out, "\n")
+	} else if evaluated != nil && evaluated.Type() != object.NULL_OBJ {
+		io.WriteString(out, evaluated.Inspect())
+		io.WriteString(out, "\n")
 	}
 }
@@ -1,10 +1,40 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // position of the first character of the token
+	End     Position // position immediately after the token
+}
+
+// Position is a location in the source being lexed
+type Position struct {
+	File   string
+	Line   int // starts at 1
+	Column int // starts at 1, counted in runes
+	Offset int // byte offset, starts at 0
+}
+
+// a zero Position (line 0) means the position is unknown
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.File != "" {
+			return p.File
+		}
+		return "-"
+	}
+	if p.File != "" {
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 // types of tokens
@@ -33,6 +63,14 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND = "&&"
+	OR  = "||"
+
+	// set algebra
+	PIPE      = "|" // union
+	AMPERSAND = "&" // intersection
+	CARET     = "^" // symmetric difference
+
 	// delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -45,6 +83,10 @@ const (
 
 	// string
 	STRING = "STRING"
+	// "a ${x} b ${y} c" is lexed as STRING_HEAD("a ") x STRING_MID(" b ") y STRING_TAIL(" c")
+	STRING_HEAD = "STRING_HEAD"
+	STRING_MID  = "STRING_MID"
+	STRING_TAIL = "STRING_TAIL"
 
 	// hash
 	COLON = ":"
@@ -67,6 +109,14 @@ const (
 
 	JABA_SAMMA_MUJI = "JABA_SAMMA_MUJI"
 	GHUMA_MUJI      = "GHUMA_MUJI"
+
+	FYAK_MUJI        = "FYAK_MUJI"
+	KOSIS_GAR_MUJI   = "KOSIS_GAR_MUJI"
+	SAMAT_MUJI       = "SAMAT_MUJI"
+	JASARI_PANI_MUJI = "JASARI_PANI_MUJI"
+
+	RUK_MUJI  = "RUK_MUJI"
+	ARKO_MUJI = "ARKO_MUJI"
 )
 
 func NewToken(t TokenType, r rune) Token {
@@ -78,16 +128,22 @@ func NewTokenFromStr(t TokenType, s string) Token {
 }
 
 var keywords = map[string]TokenType{
-	"thoos_muji":      THOOS_MUJI,
-	"kaam_gar_muji":   KAAM_GAR_MUJI,
-	"yedi_muji":       YEDI_MUJI,
-	"nabhae_chikne":   NABHAE_CHIKNE,
-	"sacho_muji":      SACHO_MUJI,
-	"jhut_muji":       JHUT_MUJI,
-	"patha_muji":      PATHA_MUJI,
-	"jaba_samma_muji": JABA_SAMMA_MUJI,
-	"ghuma_muji":      GHUMA_MUJI,
-	"nabhae_muji":     NABHAE_MUJI,
+	"thoos_muji":       THOOS_MUJI,
+	"kaam_gar_muji":    KAAM_GAR_MUJI,
+	"yedi_muji":        YEDI_MUJI,
+	"nabhae_chikne":    NABHAE_CHIKNE,
+	"sacho_muji":       SACHO_MUJI,
+	"jhut_muji":        JHUT_MUJI,
+	"patha_muji":       PATHA_MUJI,
+	"jaba_samma_muji":  JABA_SAMMA_MUJI,
+	"ghuma_muji":       GHUMA_MUJI,
+	"nabhae_muji":      NABHAE_MUJI,
+	"fyak_muji":        FYAK_MUJI,
+	"kosis_gar_muji":   KOSIS_GAR_MUJI,
+	"samat_muji":       SAMAT_MUJI,
+	"jasari_pani_muji": JASARI_PANI_MUJI,
+	"ruk_muji":         RUK_MUJI,
+	"arko_muji":        ARKO_MUJI,
 }
 
 // this distinguishes reserved keywords from variable names
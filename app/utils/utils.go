@@ -11,8 +11,9 @@ func IsLetter(ch rune) bool {
 	return unicode.IsLetter(ch) || ch == '_'
 }
 
+// only ascii digits, number literals are written with them
 func IsDigit(ch rune) bool {
-	return unicode.IsDigit(ch)
+	return '0' <= ch && ch <= '9'
 }
 
 func IsTruthy(o object.Object) bool {
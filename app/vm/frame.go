@@ -0,0 +1,55 @@
+package vm
+
+import (
+	"github.com/udeshyadhungana/interprerer/app/compiler"
+	"github.com/udeshyadhungana/interprerer/app/object"
+)
+
+// Closure is a compiled function together with the variables it captured
+type Closure struct {
+	Fn   *compiler.Function
+	Name string // name it was first bound to, empty for anonymous functions
+	free []*upvalue
+}
+
+func (c *Closure) Type() object.ObjectType { return object.KAAM_GAR_MUJI_OBJ }
+func (c *Closure) Inspect() string         { return c.Fn.Inspect() }
+
+// the captured values that left the stack, for the memory limit, the others are on the stack
+func (c *Closure) EachValue(visit func(object.Object)) {
+	for _, uv := range c.free {
+		if uv.closed {
+			visit(uv.value)
+		}
+	}
+}
+
+// upvalue is a captured variable
+// it refers to the variable's stack slot until the variable's scope ends, then keeps the last value
+type upvalue struct {
+	slot   int
+	closed bool
+	value  object.Object
+}
+
+type frame struct {
+	cl   *Closure
+	ip   int
+	bp   int             // first slot of the function's locals
+	args []object.Object // the arguments it was called with, for tracebacks
+}
+
+// handler is an active kosis_gar_muji
+type handler struct {
+	frame int // the frame it belongs to
+	sp    int
+	ip    int // where samat_muji or jasari_pani_muji starts
+}
+
+// iterator walks the iterable of a for-each ghuma_muji, it only ever lives on the stack
+type iterator struct {
+	next func() (object.Object, object.Object, bool)
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
@@ -0,0 +1,670 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/udeshyadhungana/interprerer/app/compiler"
+	"github.com/udeshyadhungana/interprerer/app/eval"
+	"github.com/udeshyadhungana/interprerer/app/object"
+	"github.com/udeshyadhungana/interprerer/app/utils"
+)
+
+const initialStackSize = 1024
+
+type VM struct {
+	main      *compiler.Function
+	constants []object.Object
+	globals   []object.Object
+	names     []string // of the globals
+	runtime   *object.Runtime
+
+	stack []object.Object
+	sp    int // next free slot
+
+	frames   []frame
+	fi       int // index of the running frame
+	handlers []handler
+	open     []*upvalue // upvalues still pointing into the stack
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobals(bytecode, nil)
+}
+
+// the repl passes the globals of the previous line, see Globals
+func NewWithGlobals(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	return NewWithRuntime(bytecode, globals, object.DefaultRuntime())
+}
+
+// builtins of the program talk to rt instead of the process' stdout and stdin
+func NewWithRuntime(bytecode *compiler.Bytecode, globals []object.Object, rt *object.Runtime) *VM {
+	for len(globals) < len(bytecode.Globals) {
+		globals = append(globals, nil)
+	}
+	return &VM{
+		main:      bytecode.Main,
+		constants: bytecode.Constants,
+		globals:   globals,
+		names:     bytecode.Globals,
+		runtime:   rt,
+		stack:     make([]object.Object, initialStackSize),
+		frames:    make([]frame, 1, 64),
+	}
+}
+
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
+// runs the program and returns the value of its last statement, or the *object.Error that stopped it
+func (vm *VM) Run() object.Object {
+	return vm.RunWithContext(context.Background())
+}
+
+// like Run, but the program stops with a LimitError once ctx is done
+func (vm *VM) RunWithContext(ctx context.Context) object.Object {
+	vm.runtime.Start(ctx)
+	vm.reset(&Closure{Fn: vm.main}, nil)
+	return vm.run()
+}
+
+// calls a function or builtin from go, the bytecode given to the vm must be the one fn was compiled with
+func (vm *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	return vm.CallWithContext(context.Background(), fn, args...)
+}
+
+func (vm *VM) CallWithContext(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	vm.runtime.Start(ctx)
+	switch callee := fn.(type) {
+	case *Closure:
+		if len(args) != len(callee.Fn.Parameters) {
+			return newError("arguments length mismatch")
+		}
+		vm.reset(callee, args)
+		return vm.run()
+	case *object.Builtin:
+		return callee.Fn(vm.runtime, args...)
+	default:
+		return newError("cannot apply %s; not a function or a builtin", fn.Type())
+	}
+}
+
+// makes cl the outermost frame, with args as its first locals
+func (vm *VM) reset(cl *Closure, args []object.Object) {
+	vm.fi = 0
+	vm.handlers = vm.handlers[:0]
+	vm.open = vm.open[:0]
+	vm.frames[0] = frame{cl: cl, args: args}
+	vm.reserve(cl.Fn.NumLocals)
+	copy(vm.stack, args)
+	for i := len(args); i < cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = cl.Fn.NumLocals
+}
+
+// the globals, the stack and the running functions, what the memory limit measures from
+func (vm *VM) EachValue(visit func(object.Object)) {
+	for _, g := range vm.globals {
+		visit(g)
+	}
+	for _, v := range vm.stack[:vm.sp] {
+		visit(v)
+	}
+	for i := 0; i <= vm.fi; i++ {
+		visit(vm.frames[i].cl)
+		for _, arg := range vm.frames[i].args {
+			visit(arg)
+		}
+	}
+}
+
+func (vm *VM) run() object.Object {
+	if vm.runtime.PushRoot(vm) {
+		defer vm.runtime.PopRoot()
+	}
+	fr := &vm.frames[vm.fi]
+	ins := fr.cl.Fn.Instructions
+
+	for {
+		ip := fr.ip
+		op := compiler.Opcode(ins[ip])
+		// running out of steps stops the program like any other error
+		err := vm.runtime.Step()
+		if err == nil {
+			switch op {
+			case compiler.OpConstant:
+				vm.push(vm.constants[compiler.ReadUint16(ins[ip+1:])])
+				fr.ip += 3
+			case compiler.OpNull:
+				vm.push(object.NULL)
+				fr.ip++
+			case compiler.OpTrue:
+				vm.push(object.TRUE)
+				fr.ip++
+			case compiler.OpFalse:
+				vm.push(object.FALSE)
+				fr.ip++
+			case compiler.OpPop:
+				vm.sp--
+				fr.ip++
+
+			case compiler.OpAdd, compiler.OpSub, compiler.OpMul, compiler.OpDiv, compiler.OpMod,
+				compiler.OpEqual, compiler.OpNotEqual, compiler.OpGreater, compiler.OpGreaterEqual,
+				compiler.OpLess, compiler.OpLessEqual,
+				compiler.OpUnion, compiler.OpIntersection, compiler.OpSymmetricDifference:
+				right := vm.stack[vm.sp-1]
+				left := vm.stack[vm.sp-2]
+				vm.sp -= 2
+				result := binary(vm.runtime, op, left, right)
+				if e, ok := result.(*object.Error); ok {
+					err = e
+					break
+				}
+				vm.push(result)
+				fr.ip++
+			case compiler.OpMinus, compiler.OpBang:
+				operator := "-"
+				if op == compiler.OpBang {
+					operator = "!"
+				}
+				result := eval.Prefix(operator, vm.stack[vm.sp-1])
+				if e, ok := result.(*object.Error); ok {
+					err = e
+					break
+				}
+				vm.stack[vm.sp-1] = result
+				fr.ip++
+
+			case compiler.OpJump:
+				fr.ip = int(compiler.ReadUint16(ins[ip+1:]))
+			case compiler.OpJumpNotTruthy:
+				vm.sp--
+				if utils.IsTruthy(vm.stack[vm.sp]) {
+					fr.ip += 3
+				} else {
+					fr.ip = int(compiler.ReadUint16(ins[ip+1:]))
+				}
+			case compiler.OpAnd, compiler.OpOr:
+				// the left operand decides the result when it is falsy for && and truthy for ||
+				if utils.IsTruthy(vm.stack[vm.sp-1]) == (op == compiler.OpOr) {
+					fr.ip = int(compiler.ReadUint16(ins[ip+1:]))
+				} else {
+					vm.sp--
+					fr.ip += 3
+				}
+
+			case compiler.OpGetGlobal:
+				val := vm.globals[compiler.ReadUint16(ins[ip+1:])]
+				if val == nil {
+					err = newError("identifier not found: %s", fr.cl.Fn.Names[ip])
+					break
+				}
+				vm.push(val)
+				fr.ip += 3
+			case compiler.OpDefineGlobal:
+				vm.globals[compiler.ReadUint16(ins[ip+1:])] = vm.define(fr, ip)
+				fr.ip += 3
+			case compiler.OpSetGlobal:
+				index := compiler.ReadUint16(ins[ip+1:])
+				if vm.globals[index] == nil {
+					err = newError("reassignment to an undefined variable %s", fr.cl.Fn.Names[ip])
+					break
+				}
+				vm.globals[index] = vm.stack[vm.sp-1]
+				fr.ip += 3
+			case compiler.OpGetLocal:
+				val := vm.stack[fr.bp+int(compiler.ReadUint16(ins[ip+1:]))]
+				if val == nil {
+					err = newError("identifier not found: %s", fr.cl.Fn.Names[ip])
+					break
+				}
+				vm.push(val)
+				fr.ip += 3
+			case compiler.OpDefineLocal:
+				vm.stack[fr.bp+int(compiler.ReadUint16(ins[ip+1:]))] = vm.define(fr, ip)
+				fr.ip += 3
+			case compiler.OpSetLocal:
+				slot := fr.bp + int(compiler.ReadUint16(ins[ip+1:]))
+				if vm.stack[slot] == nil {
+					err = newError("reassignment to an undefined variable %s", fr.cl.Fn.Names[ip])
+					break
+				}
+				vm.stack[slot] = vm.stack[vm.sp-1]
+				fr.ip += 3
+			case compiler.OpGetFree:
+				val := vm.get(fr.cl.free[ins[ip+1]])
+				if val == nil {
+					err = newError("identifier not found: %s", fr.cl.Fn.Names[ip])
+					break
+				}
+				vm.push(val)
+				fr.ip += 2
+			case compiler.OpSetFree:
+				uv := fr.cl.free[ins[ip+1]]
+				if vm.get(uv) == nil {
+					err = newError("reassignment to an undefined variable %s", fr.cl.Fn.Names[ip])
+					break
+				}
+				vm.set(uv, vm.stack[vm.sp-1])
+				fr.ip += 2
+			case compiler.OpGetBuiltin:
+				name := vm.constants[compiler.ReadUint16(ins[ip+1:])].(*object.String).Value
+				builtin, ok := eval.LookupBuiltin(name)
+				if !ok {
+					err = newError("identifier not found: %s", name)
+					break
+				}
+				vm.push(builtin)
+				fr.ip += 3
+			case compiler.OpEnterScope:
+				first := fr.bp + int(compiler.ReadUint16(ins[ip+1:]))
+				last := first + int(compiler.ReadUint16(ins[ip+3:]))
+				// closures made by an earlier run of the scope keep the values they saw
+				vm.closeUpvalues(first, last)
+				for i := first; i < last; i++ {
+					vm.stack[i] = nil
+				}
+				fr.ip += 5
+
+			case compiler.OpArray:
+				n := int(compiler.ReadUint16(ins[ip+1:]))
+				if err = vm.runtime.Allocate(object.ArraySize(n)); err != nil {
+					break
+				}
+				var elements []object.Object
+				if n > 0 {
+					elements = make([]object.Object, n)
+					copy(elements, vm.stack[vm.sp-n:vm.sp])
+				}
+				vm.sp -= n
+				vm.push(&object.Array{Arr: elements})
+				fr.ip += 3
+			case compiler.OpTuple:
+				n := int(compiler.ReadUint16(ins[ip+1:]))
+				if err = vm.runtime.Allocate(object.ArraySize(n)); err != nil {
+					break
+				}
+				elements := make([]object.Object, n)
+				copy(elements, vm.stack[vm.sp-n:vm.sp])
+				vm.sp -= n
+				vm.push(&object.Tuple{Elements: elements})
+				fr.ip += 3
+			case compiler.OpSet:
+				n := int(compiler.ReadUint16(ins[ip+1:]))
+				set := object.NewSet(n)
+				for i := vm.sp - n; i < vm.sp; i++ {
+					if err = eval.AddToSet(vm.runtime, set, vm.stack[i]); err != nil {
+						break
+					}
+				}
+				if err != nil {
+					break
+				}
+				vm.sp -= n
+				vm.push(set)
+				fr.ip += 3
+			case compiler.OpHash:
+				n := int(compiler.ReadUint16(ins[ip+1:]))
+				hash := object.NewHashMap(n)
+				for i := vm.sp - 2*n; i < vm.sp; i += 2 {
+					if err = eval.SetHashPair(vm.runtime, hash, vm.stack[i], vm.stack[i+1]); err != nil {
+						break
+					}
+				}
+				if err != nil {
+					break
+				}
+				vm.sp -= 2 * n
+				vm.push(hash)
+				fr.ip += 3
+			case compiler.OpInterpolate:
+				n := int(compiler.ReadUint16(ins[ip+1:]))
+				result := eval.Interpolate(vm.runtime, vm.stack[vm.sp-n:vm.sp])
+				if e, ok := result.(*object.Error); ok {
+					err = e
+					break
+				}
+				vm.sp -= n
+				vm.push(result)
+				fr.ip += 3
+			case compiler.OpIndex:
+				operand := vm.stack[vm.sp-1]
+				index := vm.stack[vm.sp-2]
+				vm.sp -= 2
+				result := eval.Index(operand, index)
+				if e, ok := result.(*object.Error); ok {
+					err = e
+					break
+				}
+				vm.push(result)
+				fr.ip++
+			case compiler.OpSlice:
+				operand := vm.stack[vm.sp-3]
+				low := vm.stack[vm.sp-2]
+				high := vm.stack[vm.sp-1]
+				vm.sp -= 3
+				result := eval.Slice(vm.runtime, operand, low, high)
+				if e, ok := result.(*object.Error); ok {
+					err = e
+					break
+				}
+				vm.push(result)
+				fr.ip++
+			case compiler.OpSetIndex:
+				index := vm.stack[vm.sp-1]
+				operand := vm.stack[vm.sp-2]
+				value := vm.stack[vm.sp-3]
+				vm.sp -= 3
+				result := eval.SetIndex(vm.runtime, operand, index, value)
+				if e, ok := result.(*object.Error); ok {
+					err = e
+					break
+				}
+				vm.push(result)
+				fr.ip++
+
+			case compiler.OpClosure:
+				fn := vm.constants[compiler.ReadUint16(ins[ip+1:])].(*compiler.Function)
+				cl := &Closure{Fn: fn}
+				if len(fn.Upvalues) > 0 {
+					cl.free = make([]*upvalue, len(fn.Upvalues))
+					for i, uv := range fn.Upvalues {
+						if uv.Local {
+							cl.free[i] = vm.capture(fr.bp + uv.Index)
+						} else {
+							cl.free[i] = fr.cl.free[uv.Index]
+						}
+					}
+				}
+				vm.push(cl)
+				fr.ip += 3
+			case compiler.OpCall:
+				n := int(ins[ip+1])
+				vm.sp--
+				switch callee := vm.stack[vm.sp].(type) {
+				case *Closure:
+					if n != len(callee.Fn.Parameters) {
+						err = newError("arguments length mismatch")
+						err.Pos = fr.cl.Fn.PosAt(ip)
+						err.Stack = append(err.Stack, object.NewFrame(frameName(callee, fr.cl.Fn, ip), err.Pos, vm.stack[vm.sp-n:vm.sp]))
+						break
+					}
+					if err = vm.runtime.Enter(); err != nil {
+						err.Pos = fr.cl.Fn.PosAt(ip)
+						err.Stack = append(err.Stack, object.NewFrame(frameName(callee, fr.cl.Fn, ip), err.Pos, vm.stack[vm.sp-n:vm.sp]))
+						break
+					}
+					fr.ip += 2
+					vm.call(callee, n)
+					fr = &vm.frames[vm.fi]
+					ins = fr.cl.Fn.Instructions
+				case *object.Builtin:
+					args := make([]object.Object, n)
+					copy(args, vm.stack[vm.sp-n:vm.sp])
+					vm.sp -= n
+					result := callee.Fn(vm.runtime, args...)
+					if e, ok := result.(*object.Error); ok {
+						err = e
+						break
+					}
+					vm.push(result)
+					fr.ip += 2
+				default:
+					err = newError("cannot apply %s; not a function or a builtin", fr.cl.Fn.Names[ip])
+				}
+			case compiler.OpReturnValue:
+				result := vm.stack[vm.sp-1]
+				vm.closeUpvalues(fr.bp, len(vm.stack))
+				if vm.fi == 0 {
+					return result
+				}
+				vm.sp = fr.bp
+				vm.fi--
+				vm.runtime.Leave()
+				fr = &vm.frames[vm.fi]
+				ins = fr.cl.Fn.Instructions
+				vm.push(result)
+
+			case compiler.OpTry:
+				vm.handlers = append(vm.handlers, handler{frame: vm.fi, sp: vm.sp, ip: int(compiler.ReadUint16(ins[ip+1:]))})
+				fr.ip += 3
+			case compiler.OpEndTry:
+				vm.handlers = vm.handlers[:len(vm.handlers)-1]
+				fr.ip++
+			case compiler.OpThrow:
+				vm.sp--
+				err = eval.Throw(vm.stack[vm.sp])
+
+			case compiler.OpIter:
+				vm.sp--
+				next, e := eval.Iterate(vm.stack[vm.sp], ins[ip+1] == 1)
+				if e != nil {
+					err = e
+					break
+				}
+				vm.push(&iterator{next: next})
+				fr.ip += 2
+			case compiler.OpIterNext:
+				key, value, ok := vm.stack[vm.sp-1].(*iterator).next()
+				if !ok {
+					fr.ip = int(compiler.ReadUint16(ins[ip+2:]))
+					break
+				}
+				vm.push(value)
+				if ins[ip+1] == 1 {
+					vm.push(key)
+				}
+				fr.ip += 4
+
+			default:
+				return newError("unknown opcode %d", op)
+			}
+		}
+
+		if err != nil {
+			if !vm.throw(err, ip) {
+				// closures made before the error outlive this run
+				vm.closeUpvalues(0, len(vm.stack))
+				return err
+			}
+			fr = &vm.frames[vm.fi]
+			ins = fr.cl.Fn.Instructions
+		}
+	}
+}
+
+// the value of a thoos_muji, it names the function being bound if it has no name yet
+func (vm *VM) define(fr *frame, ip int) object.Object {
+	val := vm.stack[vm.sp-1]
+	if cl, ok := val.(*Closure); ok && cl.Name == "" {
+		cl.Name = fr.cl.Fn.Names[ip]
+	}
+	return val
+}
+
+// calls cl with the n arguments on top of the stack, they become its first locals
+func (vm *VM) call(cl *Closure, n int) {
+	bp := vm.sp - n
+	vm.fi++
+	if vm.fi == len(vm.frames) {
+		vm.frames = append(vm.frames, frame{})
+	}
+	fr := &vm.frames[vm.fi]
+	fr.cl = cl
+	fr.ip = 0
+	fr.bp = bp
+	fr.args = append(fr.args[:0], vm.stack[bp:bp+n]...)
+
+	top := bp + cl.Fn.NumLocals
+	vm.reserve(top)
+	for i := bp + n; i < top; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = top
+}
+
+// hands the error to the innermost kosis_gar_muji, returns false when there is none
+// every call the error leaves is added to its stack trace
+func (vm *VM) throw(err *object.Error, ip int) bool {
+	if !err.Pos.IsValid() {
+		err.Pos = vm.frames[vm.fi].cl.Fn.PosAt(ip)
+	}
+	target := 0
+	if len(vm.handlers) > 0 {
+		target = vm.handlers[len(vm.handlers)-1].frame
+	}
+	for vm.fi > target {
+		fr := &vm.frames[vm.fi]
+		caller := &vm.frames[vm.fi-1]
+		// the caller stopped right after its OpCall
+		call := caller.ip - 2
+		err.Stack = append(err.Stack, object.NewFrame(frameName(fr.cl, caller.cl.Fn, call), caller.cl.Fn.PosAt(call), fr.args))
+		vm.fi--
+		vm.runtime.Leave()
+	}
+	if len(vm.handlers) == 0 {
+		return false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.closeUpvalues(h.sp, len(vm.stack))
+	vm.sp = h.sp
+	vm.push(&object.Exception{Err: err})
+	vm.frames[vm.fi].ip = h.ip
+	return true
+}
+
+// the name a call shows up with in a traceback
+func frameName(cl *Closure, caller *compiler.Function, call int) string {
+	if cl.Name != "" {
+		return cl.Name
+	}
+	return caller.Names[call]
+}
+
+/* Upvalues */
+func (vm *VM) capture(slot int) *upvalue {
+	for _, uv := range vm.open {
+		if uv.slot == slot {
+			return uv
+		}
+	}
+	uv := &upvalue{slot: slot}
+	vm.open = append(vm.open, uv)
+	return uv
+}
+
+// detaches the upvalues of the slots in [from, to) from the stack
+func (vm *VM) closeUpvalues(from int, to int) {
+	if len(vm.open) == 0 {
+		return
+	}
+	open := vm.open[:0]
+	for _, uv := range vm.open {
+		if uv.slot >= from && uv.slot < to {
+			uv.value = vm.stack[uv.slot]
+			uv.closed = true
+		} else {
+			open = append(open, uv)
+		}
+	}
+	vm.open = open
+}
+
+func (vm *VM) get(uv *upvalue) object.Object {
+	if uv.closed {
+		return uv.value
+	}
+	return vm.stack[uv.slot]
+}
+
+func (vm *VM) set(uv *upvalue, val object.Object) {
+	if uv.closed {
+		uv.value = val
+	} else {
+		vm.stack[uv.slot] = val
+	}
+}
+
+/* Stack */
+func (vm *VM) push(o object.Object) {
+	if vm.sp == len(vm.stack) {
+		vm.reserve(vm.sp + 1)
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+}
+
+// makes sure the stack has at least n slots
+func (vm *VM) reserve(n int) {
+	if n <= len(vm.stack) {
+		return
+	}
+	size := 2 * len(vm.stack)
+	for size < n {
+		size *= 2
+	}
+	stack := make([]object.Object, size)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+/* Operators */
+var operators = map[compiler.Opcode]string{
+	compiler.OpAdd:                 "+",
+	compiler.OpSub:                 "-",
+	compiler.OpMul:                 "*",
+	compiler.OpDiv:                 "/",
+	compiler.OpMod:                 "%",
+	compiler.OpEqual:               "==",
+	compiler.OpNotEqual:            "!=",
+	compiler.OpGreater:             ">",
+	compiler.OpGreaterEqual:        ">=",
+	compiler.OpLess:                "<",
+	compiler.OpLessEqual:           "<=",
+	compiler.OpUnion:               "|",
+	compiler.OpIntersection:        "&",
+	compiler.OpSymmetricDifference: "^",
+}
+
+// integers take a shortcut unless they overflow, everything else goes through the tree walker's operators
+func binary(rt *object.Runtime, op compiler.Opcode, left object.Object, right object.Object) object.Object {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case compiler.OpAdd:
+				if v, ok := eval.AddInt(l.Value, r.Value); ok {
+					return &object.Integer{Value: v}
+				}
+			case compiler.OpSub:
+				if v, ok := eval.SubInt(l.Value, r.Value); ok {
+					return &object.Integer{Value: v}
+				}
+			case compiler.OpMul:
+				if v, ok := eval.MulInt(l.Value, r.Value); ok {
+					return &object.Integer{Value: v}
+				}
+			case compiler.OpEqual:
+				return utils.GetBoolRef(l.Value == r.Value)
+			case compiler.OpNotEqual:
+				return utils.GetBoolRef(l.Value != r.Value)
+			case compiler.OpGreater:
+				return utils.GetBoolRef(l.Value > r.Value)
+			case compiler.OpGreaterEqual:
+				return utils.GetBoolRef(l.Value >= r.Value)
+			case compiler.OpLess:
+				return utils.GetBoolRef(l.Value < r.Value)
+			case compiler.OpLessEqual:
+				return utils.GetBoolRef(l.Value <= r.Value)
+			}
+		}
+	}
+	return eval.Infix(rt, left, operators[op], right)
+}
+
+func newError(format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.RUNTIME_ERROR}
+}